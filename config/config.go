@@ -1,107 +1,1091 @@
-package config
-
-import (
-	"encoding/json"
-	"flag"
-	"fmt"
-	"log/slog"
-	"os"
-	"strings"
-)
-
-// Configuration priority: CLI flags > ENV vars > config file
-func LoadConfig() (*Config, error) {
-	cfg := DefaultConfig()
-
-	// Define all flags first
-	var configFile string
-	flag.StringVar(&configFile, "config", "", "Path to configuration file (JSON, YAML, or TOML)")
-	
-	var denylist string
-	var listenAddr string
-	var metricsPath string
-	var wgCommandPath string
-	var showEndpoints bool
-	var readConfigFiles bool
-	
-	flag.StringVar(&denylist, "interfaces-denylist", "", "Comma-separated list of interfaces to exclude (overrides config file and env)")
-	flag.StringVar(&listenAddr, "listen-address", "", "Address to listen on for metrics endpoint (overrides config file and env)")
-	flag.StringVar(&metricsPath, "metrics-path", "", "Path for metrics endpoint (overrides config file and env)")
-	flag.StringVar(&wgCommandPath, "wg-command-path", "", "Path to wg command (overrides config file and env)")
-	flag.BoolVar(&showEndpoints, "show-endpoints", false, "Show peer endpoints in metrics (overrides config file and env)")
-	flag.BoolVar(&readConfigFiles, "read-config-files", true, "Enable reading WireGuard config files for display names (overrides config file and env)")
-
-	flag.Parse()
-
-	// 1: Load from config file (lowest priority)
-	if configFile != "" {
-		if err := loadConfigFile(cfg, configFile); err != nil {
-			return nil, fmt.Errorf("failed to load config file: %w", err)
-		}
-	}
-
-	// 2: Load from environment variables (medium priority)
-	loadFromEnv(cfg)
-
-	// 3: Apply CLI flags (highest priority) - only if they were set
-	flag.Visit(func(f *flag.Flag) {
-		switch f.Name {
-		case "interfaces-denylist":
-			cfg.InterfacesDenylist = strings.Split(denylist, ",")
-			for i := range cfg.InterfacesDenylist {
-				cfg.InterfacesDenylist[i] = strings.TrimSpace(cfg.InterfacesDenylist[i])
-			}
-		case "listen-address":
-			cfg.ListenAddress = listenAddr
-		case "metrics-path":
-			cfg.MetricsPath = metricsPath
-		case "wg-command-path":
-			cfg.WGCommandPath = wgCommandPath
-		case "show-endpoints":
-			cfg.ShowEndpoints = showEndpoints
-		case "read-config-files":
-			cfg.ReadConfigFiles = readConfigFiles
-		}
-	})
-
-	slog.Info("Configuration loaded", "listen_address", cfg.ListenAddress, "metrics_path", cfg.MetricsPath)
-	slog.Debug("Full Configuration dump", "config", cfg)
-	return cfg, nil
-}
-
-func loadConfigFile(cfg *Config, path string) error {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
-
-	return json.Unmarshal(data, cfg)
-}
-
-func loadFromEnv(cfg *Config) {
-	if val := os.Getenv("WG_LISTEN_ADDRESS"); val != "" {
-		cfg.ListenAddress = val
-	}
-	if val := os.Getenv("WG_METRICS_PATH"); val != "" {
-		cfg.MetricsPath = val
-	}
-	if val := os.Getenv("WG_INTERFACES_DENYLIST"); val != "" {
-		cfg.InterfacesDenylist = strings.Split(val, ",")
-		for i := range cfg.InterfacesDenylist {
-			cfg.InterfacesDenylist[i] = strings.TrimSpace(cfg.InterfacesDenylist[i])
-		}
-	}
-	if val := os.Getenv("WG_COMMAND_PATH"); val != "" {
-		cfg.WGCommandPath = val
-	}
-	if val := os.Getenv("WG_SHOW_ENDPOINTS"); val != "" {
-		cfg.ShowEndpoints = strings.ToLower(val) == "true" || val == "1"
-	}
-	if val := os.Getenv("WG_READ_CONFIG_FILES"); val != "" {
-		cfg.ReadConfigFiles = strings.ToLower(val) == "true" || val == "1"
-	}
-	// Interface labels from env would need a specific format, skipping for now
-	// Config file paths would need a specific format, skipping for now
-}
-
-
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Set by LoadConfig so ReloadConfig can rebuild the configuration with the same flags
+var (
+	configFilePaths    []string
+	configFetchTimeout time.Duration // From the flag, zero when it was not set
+	applyFlags         func(cfg *Config)
+)
+
+// Timeout for fetching a config file from an http(s) URL, unless set by flag or env
+const defaultConfigFetchTimeout = 10 * time.Second
+
+// configFileList collects -config values, each one may also be a comma-separated list
+type configFileList []string
+
+func (l *configFileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *configFileList) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			*l = append(*l, path)
+		}
+	}
+	return nil
+}
+
+// Configuration priority: CLI flags > ENV vars > config file
+func LoadConfig() (*Config, error) {
+	// Define all flags first
+	var configFiles configFileList
+	flag.Var(&configFiles, "config", "Path or http(s) URL of a configuration file (JSON), comma-separated or repeated to merge several, later files override earlier ones")
+	var fetchTimeout time.Duration
+	flag.DurationVar(&fetchTimeout, "config-fetch-timeout", 0, "Timeout for fetching configuration files from http(s) URLs, default 10s (overrides env)")
+	
+	var denylist string
+	var regexMatchInterfaces bool
+	var reportDeniedInterfaces bool
+	var expectedInterfaces string
+	var listenAddr string
+	var logFormat string
+	var metricsPath string
+	var tlsCertFile string
+	var tlsKeyFile string
+	var tlsClientCAFile string
+	var disableLandingPage bool
+	var landingPageHTML string
+	var enablePprof bool
+	var pprofAddress string
+	var enableRuntimeMetrics bool
+	var metricNamespace string
+	var nodeLabel string
+	var enableInterfacesJSON bool
+	var redactPublicKeys bool
+	var wgCommandPath string
+	var wgCommand string
+	var dumpFile string
+	var commandRetries int
+	var circuitBreakerThreshold int
+	var circuitBreakerCooldown time.Duration
+	var outputFormat string
+	var maxConcurrency int
+	var strictMode bool
+	var showEndpoints bool
+	var resolveEndpointHostnames bool
+	var enableExemplars bool
+	var aggregateOnly bool
+	var maxPeersPerInterface int
+	var peerKeyLabelMode string
+	var labelDenylist string
+	var peersDenylist string
+	var peersDenylistExcludeTotals bool
+	var showAllowedIPs bool
+	var peerInfoAllowedIPsMaxLength int
+	var detectAllowedIPOverlaps bool
+	var trackedSubnets string
+	var readConfigFiles bool
+	var configDir string
+	var readShowconf bool
+	var stateFile string
+	var maxPeerStaleness time.Duration
+	var peerUpThreshold time.Duration
+	var keepaliveOverdueFactor float64
+	var minScrapeInterval time.Duration
+	var httpReadTimeout time.Duration
+	var httpWriteTimeout time.Duration
+	var httpIdleTimeout time.Duration
+	
+	flag.StringVar(&denylist, "interfaces-denylist", "", "Comma-separated list of interfaces to exclude (overrides config file and env)")
+	flag.StringVar(&expectedInterfaces, "expected-interfaces", "", "Comma-separated list of interfaces reported as down when they are not up (overrides config file and env)")
+	flag.BoolVar(&regexMatchInterfaces, "regex-match-interfaces", false, "Treat interfaces denylist entries as regular expressions (overrides config file and env)")
+	flag.BoolVar(&reportDeniedInterfaces, "report-denied-interfaces", false, "Report denylisted interfaces by name in wireguard_interface_denied (overrides config file and env)")
+	flag.StringVar(&listenAddr, "listen-address", "", "Address to listen on for metrics endpoint (overrides config file and env)")
+	flag.StringVar(&logFormat, "log-format", "", "Log format, text or json (overrides config file and env)")
+	flag.StringVar(&metricsPath, "metrics-path", "", "Path for metrics endpoint (overrides config file and env)")
+	flag.StringVar(&tlsCertFile, "tls-cert-file", "", "Certificate file to serve HTTPS, requires the key file (overrides config file and env)")
+	flag.StringVar(&tlsKeyFile, "tls-key-file", "", "Key file of the TLS certificate (overrides config file and env)")
+	flag.StringVar(&tlsClientCAFile, "tls-client-ca-file", "", "CA file to require and verify client certificates, requires TLS (overrides config file and env)")
+	flag.BoolVar(&disableLandingPage, "disable-landing-page", false, "Answer 404 on / instead of serving the landing page (overrides config file and env)")
+	flag.StringVar(&landingPageHTML, "landing-page-html", "", "Custom HTML body of the landing page (overrides config file and env)")
+	flag.BoolVar(&enablePprof, "pprof", false, "Serve pprof profiling endpoints on the pprof address (overrides config file and env)")
+	flag.StringVar(&pprofAddress, "pprof-address", "", "Address to serve pprof on, separate from the metrics endpoint (overrides config file and env)")
+	flag.BoolVar(&enableRuntimeMetrics, "enable-runtime-metrics", false, "Report the exporter's own goroutine count and heap size (overrides config file and env)")
+	flag.StringVar(&nodeLabel, "node-label", "", "Value of the node label added to every metric, auto for the hostname (overrides config file and env)")
+	flag.StringVar(&metricNamespace, "metric-namespace", "", "Prefix of all metric names (overrides config file and env)")
+	flag.BoolVar(&enableInterfacesJSON, "enable-interfaces-json", false, "Serve the parsed interfaces and peers as JSON on /interfaces.json (overrides config file and env)")
+	flag.BoolVar(&redactPublicKeys, "redact-public-keys", false, "Shorten public keys in /interfaces.json (overrides config file and env)")
+	flag.StringVar(&wgCommandPath, "wg-command-path", "", "Path to wg command (overrides config file and env)")
+	flag.StringVar(&wgCommand, "wg-command", "", "wg command with a wrapper and leading arguments, split on spaces, e.g. \"nsenter -t 1 -n wg\" (overrides config file and env)")
+	flag.StringVar(&dumpFile, "dump-file", "", "Read one interface from a captured wg show <interface> dump file instead of running wg, - for stdin (overrides config file and env)")
+	flag.IntVar(&maxConcurrency, "max-concurrency", 0, "Maximum number of interfaces fetched at once (overrides config file and env)")
+	flag.IntVar(&commandRetries, "command-retries", 0, "Retries when a wg command fails to execute (overrides config file and env)")
+	flag.IntVar(&circuitBreakerThreshold, "circuit-breaker-threshold", 0, "Consecutive failures after which an interface is skipped for the cooldown, 0 disables (overrides config file and env)")
+	flag.DurationVar(&circuitBreakerCooldown, "circuit-breaker-cooldown", 0, "How long a consistently failing interface is skipped, e.g. 5m (overrides config file and env)")
+	flag.StringVar(&outputFormat, "output-format", "", "wg output format to parse, dump or human (overrides config file and env)")
+	flag.BoolVar(&strictMode, "strict", false, "Exit at startup if the wg command cannot be found or a config file has unknown fields (overrides config file and env)")
+	flag.BoolVar(&showEndpoints, "show-endpoints", false, "Show peer endpoints in metrics (overrides config file and env)")
+	flag.BoolVar(&resolveEndpointHostnames, "resolve-endpoint-hostnames", false, "Look up the hostnames of peer endpoint IPs with reverse DNS for wireguard_peer_info (overrides config file and env)")
+	flag.BoolVar(&enableExemplars, "enable-exemplars", false, "Attach exemplars with the peer key and endpoint to the peer byte counters, served to OpenMetrics scrapers (overrides config file and env)")
+	flag.BoolVar(&aggregateOnly, "aggregate-only", false, "Only export interface-level metrics, no per-peer series (overrides config file and env)")
+	flag.IntVar(&maxPeersPerInterface, "max-peers-per-interface", 0, "Maximum number of peers per interface with peer metrics, the most recent handshakes first, 0 disables the limit (overrides config file and env)")
+	flag.StringVar(&labelDenylist, "label-denylist", "", "Comma-separated list of labels whose values are hidden, e.g. endpoint,public_key (overrides config file and env)")
+	flag.StringVar(&peersDenylist, "peers-denylist", "", "Comma-separated list of peer public keys or key prefixes to exclude from peer metrics (overrides config file and env)")
+	flag.BoolVar(&peersDenylistExcludeTotals, "peers-denylist-exclude-totals", false, "Also exclude denylisted peers from interface-level metrics like the peer count (overrides config file and env)")
+	flag.StringVar(&peerKeyLabelMode, "peer-key-label-mode", "", "How public keys appear in the peer label: full, short or hash (overrides config file and env)")
+	flag.BoolVar(&showAllowedIPs, "show-allowed-ips", false, "Show peer allowed IPs in metrics (overrides config file and env)")
+	flag.IntVar(&peerInfoAllowedIPsMaxLength, "peer-info-allowed-ips-max-length", 0, "Maximum length of the allowed_ips label of wireguard_peer_info, 0 disables truncation (overrides config file and env)")
+	flag.StringVar(&trackedSubnets, "tracked-subnets", "", "Comma-separated list of subnets whose peers are counted, e.g. 10.1.0.0/24,10.2.0.0/24 (overrides config file and env)")
+	flag.BoolVar(&detectAllowedIPOverlaps, "detect-allowed-ip-overlaps", false, "Count overlapping allowed IPs between peers of an interface, O(n^2) (overrides config file and env)")
+	flag.BoolVar(&readConfigFiles, "read-config-files", true, "Enable reading WireGuard config files for display names (overrides config file and env)")
+	flag.StringVar(&configDir, "config-dir", "", "Directory of the WireGuard <interface>.conf files (overrides config file and env)")
+
+	flag.DurationVar(&httpReadTimeout, "http-read-timeout", 0, "Maximum duration for reading a request to the HTTP server (overrides config file and env)")
+	flag.DurationVar(&httpWriteTimeout, "http-write-timeout", 0, "Maximum duration for writing a response of the HTTP server, including the scrape (overrides config file and env)")
+	flag.DurationVar(&httpIdleTimeout, "http-idle-timeout", 0, "Maximum duration a keep-alive connection of the HTTP server stays idle (overrides config file and env)")
+	flag.DurationVar(&minScrapeInterval, "min-scrape-interval", 0, "Serve the previous scrape again when scraped sooner than this, e.g. 30s, 0 disables (overrides config file and env)")
+	flag.DurationVar(&peerUpThreshold, "peer-up-threshold", 0, "Peers with a handshake at most this old count as active, e.g. 3m (overrides config file and env)")
+	flag.Float64Var(&keepaliveOverdueFactor, "keepalive-overdue-factor", 0, "Multiple of the persistent keepalive interval, on top of the 2 minute rekey interval, after which a handshake is overdue (overrides config file and env)")
+	flag.DurationVar(&maxPeerStaleness, "max-peer-staleness", 0, "Skip peer metrics of peers without a handshake within this duration, e.g. 24h, 0 disables (overrides config file and env)")
+	flag.BoolVar(&readShowconf, "read-showconf", false, "Enrich peers from wg showconf output (overrides config file and env)")
+	flag.StringVar(&stateFile, "state-file", "", "File to persist byte counter totals across restarts (overrides config file and env)")
+
+	flag.Parse()
+
+	configFilePaths = configFiles
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "config-fetch-timeout" {
+			configFetchTimeout = fetchTimeout
+		}
+	})
+	applyFlags = func(cfg *Config) {
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "interfaces-denylist":
+				cfg.InterfacesDenylist = strings.Split(denylist, ",")
+				for i := range cfg.InterfacesDenylist {
+					cfg.InterfacesDenylist[i] = strings.TrimSpace(cfg.InterfacesDenylist[i])
+				}
+			case "regex-match-interfaces":
+				cfg.RegexMatchInterfaces = regexMatchInterfaces
+			case "report-denied-interfaces":
+				cfg.ReportDeniedInterfaces = reportDeniedInterfaces
+			case "expected-interfaces":
+				cfg.ExpectedInterfaces = strings.Split(expectedInterfaces, ",")
+				for i := range cfg.ExpectedInterfaces {
+					cfg.ExpectedInterfaces[i] = strings.TrimSpace(cfg.ExpectedInterfaces[i])
+				}
+			case "listen-address":
+				cfg.ListenAddress = listenAddr
+			case "log-format":
+				cfg.LogFormat = logFormat
+			case "metrics-path":
+				cfg.MetricsPath = metricsPath
+			case "tls-cert-file":
+				cfg.TLSCertFile = tlsCertFile
+			case "tls-key-file":
+				cfg.TLSKeyFile = tlsKeyFile
+			case "tls-client-ca-file":
+				cfg.TLSClientCAFile = tlsClientCAFile
+			case "disable-landing-page":
+				cfg.DisableLandingPage = disableLandingPage
+			case "landing-page-html":
+				cfg.LandingPageHTML = landingPageHTML
+			case "pprof":
+				cfg.EnablePprof = enablePprof
+			case "pprof-address":
+				cfg.PprofAddress = pprofAddress
+			case "enable-runtime-metrics":
+				cfg.EnableRuntimeMetrics = enableRuntimeMetrics
+			case "metric-namespace":
+				cfg.MetricNamespace = metricNamespace
+			case "node-label":
+				cfg.NodeLabel = nodeLabel
+			case "enable-interfaces-json":
+				cfg.EnableInterfacesJSON = enableInterfacesJSON
+			case "redact-public-keys":
+				cfg.RedactPublicKeys = redactPublicKeys
+			case "wg-command-path":
+				cfg.WGCommandPath = wgCommandPath
+				cfg.WGCommand = nil
+			case "wg-command":
+				cfg.WGCommand = strings.Fields(wgCommand)
+			case "dump-file":
+				cfg.DumpFile = dumpFile
+			case "max-concurrency":
+				cfg.MaxConcurrency = maxConcurrency
+			case "command-retries":
+				cfg.CommandRetries = commandRetries
+			case "circuit-breaker-threshold":
+				cfg.CircuitBreakerThreshold = circuitBreakerThreshold
+			case "circuit-breaker-cooldown":
+				cfg.CircuitBreakerCooldown = Duration(circuitBreakerCooldown)
+			case "output-format":
+				cfg.OutputFormat = outputFormat
+			case "strict":
+				cfg.StrictMode = strictMode
+			case "show-endpoints":
+				cfg.ShowEndpoints = showEndpoints
+			case "resolve-endpoint-hostnames":
+				cfg.ResolveEndpointHostnames = resolveEndpointHostnames
+			case "aggregate-only":
+				cfg.AggregateOnly = aggregateOnly
+			case "max-peers-per-interface":
+				cfg.MaxPeersPerInterface = maxPeersPerInterface
+			case "enable-exemplars":
+				cfg.EnableExemplars = enableExemplars
+			case "label-denylist":
+				cfg.LabelDenylist = strings.Split(labelDenylist, ",")
+				for i := range cfg.LabelDenylist {
+					cfg.LabelDenylist[i] = strings.TrimSpace(cfg.LabelDenylist[i])
+				}
+			case "peers-denylist":
+				cfg.PeersDenylist = strings.Split(peersDenylist, ",")
+				for i := range cfg.PeersDenylist {
+					cfg.PeersDenylist[i] = strings.TrimSpace(cfg.PeersDenylist[i])
+				}
+			case "peers-denylist-exclude-totals":
+				cfg.PeersDenylistExcludeTotals = peersDenylistExcludeTotals
+			case "peer-key-label-mode":
+				cfg.PeerKeyLabelMode = peerKeyLabelMode
+			case "show-allowed-ips":
+				cfg.ShowAllowedIPs = showAllowedIPs
+			case "peer-info-allowed-ips-max-length":
+				cfg.PeerInfoAllowedIPsMaxLength = peerInfoAllowedIPsMaxLength
+			case "tracked-subnets":
+				cfg.TrackedSubnets = strings.Split(trackedSubnets, ",")
+				for i := range cfg.TrackedSubnets {
+					cfg.TrackedSubnets[i] = strings.TrimSpace(cfg.TrackedSubnets[i])
+				}
+			case "detect-allowed-ip-overlaps":
+				cfg.DetectAllowedIPOverlaps = detectAllowedIPOverlaps
+			case "config-dir":
+				cfg.ConfigDir = configDir
+			case "read-config-files":
+				cfg.ReadConfigFiles = readConfigFiles
+			case "min-scrape-interval":
+				cfg.MinScrapeInterval = Duration(minScrapeInterval)
+			case "http-read-timeout":
+				cfg.HTTPReadTimeout = Duration(httpReadTimeout)
+			case "http-write-timeout":
+				cfg.HTTPWriteTimeout = Duration(httpWriteTimeout)
+			case "http-idle-timeout":
+				cfg.HTTPIdleTimeout = Duration(httpIdleTimeout)
+			case "peer-up-threshold":
+				cfg.PeerUpThreshold = Duration(peerUpThreshold)
+			case "keepalive-overdue-factor":
+				cfg.KeepaliveOverdueFactor = keepaliveOverdueFactor
+			case "max-peer-staleness":
+				cfg.MaxPeerStaleness = Duration(maxPeerStaleness)
+			case "read-showconf":
+				cfg.ReadShowconf = readShowconf
+			case "state-file":
+				cfg.StateFile = stateFile
+			}
+		})
+	}
+
+	return buildConfig()
+}
+
+// ReloadConfig re-reads the config file and environment variables. CLI flags from
+// startup are applied again, so they keep their priority
+func ReloadConfig() (*Config, error) {
+	if applyFlags == nil {
+		return nil, fmt.Errorf("configuration has not been loaded yet")
+	}
+	return buildConfig()
+}
+
+func buildConfig() (*Config, error) {
+	cfg := DefaultConfig()
+
+	// 1: Load from config files (lowest priority), in order. Each file only overrides the
+	// fields it sets and adds to the maps, so later files are merged over earlier ones
+	var unknownFields []error
+	fetchTimeout := resolveConfigFetchTimeout()
+	for _, path := range configFilePaths {
+		data, err := readConfigFile(path, fetchTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+		}
+		if err := describeDecodeError(data, decodeConfigFile(data, cfg)); err != nil {
+			return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+		}
+		if err := checkUnknownFields(data, path); err != nil {
+			unknownFields = append(unknownFields, err)
+		}
+	}
+
+	// Expand env references in the merged file values, before env vars override them
+	if cfg.ExpandEnv {
+		expandEnv(reflect.ValueOf(cfg).Elem())
+	}
+
+	// 2: Load from environment variables (medium priority)
+	loadFromEnv(cfg)
+
+	// 3: Apply CLI flags (highest priority) - only if they were set
+	applyFlags(cfg)
+
+	// Usually a typo, the field is ignored. Checked once the strict mode setting is final
+	for _, err := range unknownFields {
+		if cfg.StrictMode {
+			return nil, err
+		}
+		slog.Warn("Ignoring unknown config file field", "error", err)
+	}
+
+	if err := normalizeListenAddress(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateMetricsPath(cfg.MetricsPath); err != nil {
+		return nil, err
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("invalid TLS configuration: the certificate and key files must be set together")
+	}
+	if cfg.TLSClientCAFile != "" && cfg.TLSCertFile == "" {
+		return nil, fmt.Errorf("invalid TLS configuration: client certificate verification requires the server certificate and key files")
+	}
+
+	if cfg.HTTPReadTimeout < 0 || cfg.HTTPWriteTimeout < 0 || cfg.HTTPIdleTimeout < 0 {
+		return nil, fmt.Errorf("invalid HTTP timeouts (read %s, write %s, idle %s): must not be negative", time.Duration(cfg.HTTPReadTimeout), time.Duration(cfg.HTTPWriteTimeout), time.Duration(cfg.HTTPIdleTimeout))
+	}
+
+	cfg.LogFormat = strings.ToLower(strings.TrimSpace(cfg.LogFormat))
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		return nil, fmt.Errorf("invalid log format %q (expected text or json)", cfg.LogFormat)
+	}
+
+	if err := resolveNodeLabel(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := validateInterfaceLabels(cfg); err != nil {
+		return nil, err
+	}
+
+	// Catch typos, an unknown name would silently hide nothing
+	for _, name := range cfg.LabelDenylist {
+		if !builtinLabelNames[name] && !slices.Contains(cfg.CustomLabelNames(), name) {
+			return nil, fmt.Errorf("invalid label denylist entry %q: no metric has this label", name)
+		}
+		if unredactableLabelNames[name] {
+			return nil, fmt.Errorf("invalid label denylist entry %q: its value is never hidden", name)
+		}
+	}
+
+	if cfg.RegexMatchInterfaces {
+		if err := compileDenylist(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// A plain wg_command_path is the same as a one-element wg_command
+	if len(cfg.WGCommand) == 0 {
+		cfg.WGCommand = []string{cfg.WGCommandPath}
+	}
+	if cfg.WGCommand[0] == "" {
+		return nil, fmt.Errorf("invalid wg command: the executable must not be empty")
+	}
+	for name := range cfg.CommandEnv {
+		if name == "" || strings.Contains(name, "=") {
+			return nil, fmt.Errorf("invalid command environment variable name %q", name)
+		}
+	}
+	for target, command := range cfg.RemoteTargets {
+		if target == "" {
+			return nil, fmt.Errorf("invalid remote target: the name must not be empty")
+		}
+		if len(command) == 0 || command[0] == "" {
+			return nil, fmt.Errorf("invalid wg command of remote target %s: the executable must not be empty", target)
+		}
+	}
+
+	if err := parseTrackedSubnets(cfg); err != nil {
+		return nil, err
+	}
+
+	if cfg.MaxConcurrency < 1 {
+		return nil, fmt.Errorf("invalid max concurrency %d (must be at least 1)", cfg.MaxConcurrency)
+	}
+
+	if !(cfg.KeepaliveOverdueFactor > 0) {
+		return nil, fmt.Errorf("invalid keepalive overdue factor %g (must be positive)", cfg.KeepaliveOverdueFactor)
+	}
+
+	if cfg.MaxPeersPerInterface < 0 {
+		return nil, fmt.Errorf("invalid max peers per interface %d (must be 0 or more)", cfg.MaxPeersPerInterface)
+	}
+
+	if cfg.CommandRetries < 0 {
+		return nil, fmt.Errorf("invalid command retries %d (must be 0 or more)", cfg.CommandRetries)
+	}
+
+	if cfg.CircuitBreakerThreshold < 0 {
+		return nil, fmt.Errorf("invalid circuit breaker threshold %d (must be 0 or more)", cfg.CircuitBreakerThreshold)
+	}
+	if cfg.CircuitBreakerThreshold > 0 && cfg.CircuitBreakerCooldown <= 0 {
+		return nil, fmt.Errorf("invalid circuit breaker cooldown %s (must be positive)", time.Duration(cfg.CircuitBreakerCooldown))
+	}
+
+	cfg.OutputFormat = strings.ToLower(strings.TrimSpace(cfg.OutputFormat))
+	if cfg.OutputFormat != "dump" && cfg.OutputFormat != "human" {
+		return nil, fmt.Errorf("invalid output format %q (expected dump or human)", cfg.OutputFormat)
+	}
+	if cfg.DumpFile != "" && cfg.OutputFormat != "dump" {
+		return nil, fmt.Errorf("a dump file can only be read with the dump output format, got %q", cfg.OutputFormat)
+	}
+
+	cfg.PeerKeyLabelMode = strings.ToLower(strings.TrimSpace(cfg.PeerKeyLabelMode))
+	if cfg.PeerKeyLabelMode != "full" && cfg.PeerKeyLabelMode != "short" && cfg.PeerKeyLabelMode != "hash" {
+		return nil, fmt.Errorf("invalid peer key label mode %q (expected full, short or hash)", cfg.PeerKeyLabelMode)
+	}
+
+	// Not fatal, interfaces with an explicit config file path still get display names
+	if cfg.ReadConfigFiles {
+		if info, err := os.Stat(cfg.ConfigDir); err != nil || !info.IsDir() {
+			slog.Warn("WireGuard config directory is not readable, display names are only read from explicit config file paths", "config_dir", cfg.ConfigDir, "error", err)
+		}
+		if err := checkConfigFilePaths(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Buckets are matched in order, so keep them ascending
+	sort.Slice(cfg.HandshakeAgeBuckets, func(i, j int) bool {
+		return cfg.HandshakeAgeBuckets[i] < cfg.HandshakeAgeBuckets[j]
+	})
+	for i, bound := range cfg.HandshakeAgeBuckets {
+		if bound <= 0 {
+			return nil, fmt.Errorf("invalid handshake age bucket %s: must be positive", time.Duration(bound))
+		}
+		// A second bucket with the same bound would overwrite the count of the first
+		if i > 0 && bound == cfg.HandshakeAgeBuckets[i-1] {
+			return nil, fmt.Errorf("invalid handshake age buckets: %s is listed twice", time.Duration(bound))
+		}
+	}
+
+	slog.Info("Configuration loaded", "listen_address", cfg.ListenAddress, "metrics_path", cfg.MetricsPath)
+	slog.Debug("Full Configuration dump", "config", redactedConfig(cfg))
+	return cfg, nil
+}
+
+// Copy of cfg safe to log. Values of CommandEnv and the arguments of remote target commands
+// can carry credentials for the wrapper command, only the variable names and executables are kept
+func redactedConfig(cfg *Config) *Config {
+	redacted := *cfg
+	redacted.CommandEnv = make(map[string]string, len(cfg.CommandEnv))
+	for name := range cfg.CommandEnv {
+		redacted.CommandEnv[name] = "(hidden)"
+	}
+	redacted.RemoteTargets = make(map[string][]string, len(cfg.RemoteTargets))
+	for target, command := range cfg.RemoteTargets {
+		if len(command) > 1 {
+			command = []string{command[0], "(hidden)"}
+		}
+		redacted.RemoteTargets[target] = command
+	}
+	return &redacted
+}
+
+// Check the listen address is a valid host:port pair so typos fail at startup
+// instead of deep in ListenAndServe. Both ":9586" and "0.0.0.0:9586" are accepted,
+// as well as Unix sockets like "unix:/run/wg-exporter.sock"
+func normalizeListenAddress(cfg *Config) error {
+	addr := strings.TrimSpace(cfg.ListenAddress)
+
+	if strings.HasPrefix(addr, "unix:") {
+		if strings.TrimPrefix(addr, "unix:") == "" {
+			return fmt.Errorf("invalid listen address %q: missing socket path after unix:", cfg.ListenAddress)
+		}
+		cfg.ListenAddress = addr
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q (expected host:port or :port): %w", cfg.ListenAddress, err)
+	}
+	if portNum, err := strconv.Atoi(port); err != nil || portNum < 0 || portNum > 65535 {
+		return fmt.Errorf("invalid listen address %q: port %q must be a number between 0 and 65535", cfg.ListenAddress, port)
+	}
+
+	cfg.ListenAddress = net.JoinHostPort(host, port)
+	return nil
+}
+
+// The metrics path shares the mux with "/", "/health", "/ready" and "/interfaces.json", so it must not clash with them
+func validateMetricsPath(path string) error {
+	switch {
+	case path == "":
+		return fmt.Errorf("invalid metrics path: must not be empty")
+	case !strings.HasPrefix(path, "/"):
+		return fmt.Errorf("invalid metrics path %q: must start with /", path)
+	case path == "/":
+		return fmt.Errorf("invalid metrics path %q: clashes with the landing page", path)
+	case path == "/health":
+		return fmt.Errorf("invalid metrics path %q: clashes with the health endpoint", path)
+	case path == "/ready":
+		return fmt.Errorf("invalid metrics path %q: clashes with the readiness endpoint", path)
+	case path == "/interfaces.json":
+		return fmt.Errorf("invalid metrics path %q: clashes with the interfaces endpoint", path)
+	case path == "/probe":
+		return fmt.Errorf("invalid metrics path %q: clashes with the probe endpoint", path)
+	}
+	return nil
+}
+
+var labelNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Label names set by the exporter itself, custom labels cannot reuse them
+var builtinLabelNames = map[string]bool{
+	"interface": true, "peer": true, "endpoint": true, "endpoint_ip": true, "endpoint_port": true,
+	"address_family": true, "allowed_ip": true, "allowed_ips": true, "public_key": true,
+	"display_name": true, "latest_handshake": true, "bucket": true, "version": true, "node": true, "endpoint_type": true, "subnet": true, "endpoint_hostname": true, "address": true, "target": true,
+}
+
+// Builtin labels that are not taken from the WireGuard setup: node and target are chosen by
+// the operator, version is the version of wg. Leave node_label empty to drop the node label
+var unredactableLabelNames = map[string]bool{
+	"node": true, "version": true, "target": true,
+}
+
+// Replace a node label of "auto" with the hostname of the machine
+func resolveNodeLabel(cfg *Config) error {
+	cfg.NodeLabel = strings.TrimSpace(cfg.NodeLabel)
+	if cfg.NodeLabel != "auto" {
+		return nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to detect the hostname for the node label: %w", err)
+	}
+	cfg.NodeLabel = hostname
+	return nil
+}
+
+// Explicit config file paths that cannot be read would silently leave their interface without
+// display names. Only fatal in strict mode, some interfaces may legitimately lack a config file
+func checkConfigFilePaths(cfg *Config) error {
+	ifaces := make([]string, 0, len(cfg.ConfigFilePaths))
+	for iface := range cfg.ConfigFilePaths {
+		ifaces = append(ifaces, iface)
+	}
+	slices.Sort(ifaces)
+
+	for _, iface := range ifaces {
+		path := cfg.ConfigFilePaths[iface]
+		err := checkReadableFile(path)
+		if err == nil {
+			continue
+		}
+		if cfg.StrictMode {
+			return fmt.Errorf("config file %s of interface %s is not readable: %w", path, iface, err)
+		}
+		slog.Warn("WireGuard config file is not readable, the interface gets no display names", "interface", iface, "path", path, "error", err)
+	}
+	return nil
+}
+
+func checkReadableFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("is a directory")
+	}
+	return nil
+}
+
+// Custom interface labels must be valid Prometheus label names that don't clash with built-in ones
+func validateInterfaceLabels(cfg *Config) error {
+	for iface, labels := range cfg.InterfaceLabels {
+		if IsGlobPattern(iface) {
+			if _, err := path.Match(iface, ""); err != nil {
+				return fmt.Errorf("invalid interface pattern %q in interface labels: %w", iface, err)
+			}
+		}
+		for name := range labels {
+			if !labelNameRegex.MatchString(name) || strings.HasPrefix(name, "__") {
+				return fmt.Errorf("invalid label name %q for interface %s", name, iface)
+			}
+			if builtinLabelNames[name] {
+				return fmt.Errorf("label name %q for interface %s clashes with a built-in label", name, iface)
+			}
+		}
+	}
+	return nil
+}
+
+// Compile the denylist entries once. Patterns must match the whole interface name
+func compileDenylist(cfg *Config) error {
+	cfg.InterfacesDenylistPatterns = nil
+	for _, entry := range cfg.InterfacesDenylist {
+		if entry == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + entry + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid interfaces denylist pattern %q: %w", entry, err)
+		}
+		cfg.InterfacesDenylistPatterns = append(cfg.InterfacesDenylistPatterns, re)
+	}
+	return nil
+}
+
+// Parse the tracked subnets into TrackedSubnetNets, empty entries are skipped
+func parseTrackedSubnets(cfg *Config) error {
+	cfg.TrackedSubnetNets = nil
+	for _, entry := range cfg.TrackedSubnets {
+		if entry == "" {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return fmt.Errorf("invalid tracked subnet %q: %w", entry, err)
+		}
+		cfg.TrackedSubnetNets = append(cfg.TrackedSubnetNets, subnet)
+	}
+	return nil
+}
+
+// Expand $VAR and ${VAR} in every string, string slice and string map field
+func expandEnv(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(os.ExpandEnv(field.String()))
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.String {
+				for j := 0; j < field.Len(); j++ {
+					field.Index(j).SetString(os.ExpandEnv(field.Index(j).String()))
+				}
+			}
+		case reflect.Map:
+			if field.Type().Elem().Kind() == reflect.String && !field.IsNil() {
+				for _, key := range field.MapKeys() {
+					field.SetMapIndex(key, reflect.ValueOf(os.ExpandEnv(field.MapIndex(key).String())))
+				}
+			}
+		}
+	}
+}
+
+// Largest config file accepted from a URL, a real one is a few KiB
+const maxRemoteConfigSize = 1 << 20
+
+// Read a config file from disk, or fetch it when path is an http(s) URL. A failed fetch is an
+// error like a missing file: startup fails, and a reload keeps the current configuration
+func readConfigFile(path string, timeout time.Duration) ([]byte, error) {
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		return os.ReadFile(path)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch config: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	if len(data) > maxRemoteConfigSize {
+		return nil, fmt.Errorf("failed to fetch config: larger than %d bytes", maxRemoteConfigSize)
+	}
+	return data, nil
+}
+
+// Fetch timeout for config URLs: the flag, then WG_CONFIG_FETCH_TIMEOUT, then the default.
+// It is needed before the config files are read, so it can't be set in one
+func resolveConfigFetchTimeout() time.Duration {
+	if configFetchTimeout > 0 {
+		return configFetchTimeout
+	}
+	if val := os.Getenv("WG_CONFIG_FETCH_TIMEOUT"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err == nil {
+			return d
+		}
+		slog.Warn("Ignoring invalid WG_CONFIG_FETCH_TIMEOUT", "value", val, "error", err)
+	}
+	return defaultConfigFetchTimeout
+}
+
+// Decode a config file over cfg. encoding/json adds to the existing maps, but replaces the
+// labels of an interface as a whole, so those are merged label by label instead
+func decodeConfigFile(data []byte, cfg *Config) error {
+	previous := make(map[string]map[string]string, len(cfg.InterfaceLabels))
+	for ifaceName, labels := range cfg.InterfaceLabels {
+		previous[ifaceName] = labels
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return err
+	}
+
+	// Set to null, all labels of the earlier files are dropped
+	if cfg.InterfaceLabels == nil {
+		return nil
+	}
+	for ifaceName, labels := range previous {
+		merged := make(map[string]string, len(labels))
+		for name, value := range labels {
+			merged[name] = value
+		}
+		for name, value := range cfg.InterfaceLabels[ifaceName] {
+			merged[name] = value
+		}
+		cfg.InterfaceLabels[ifaceName] = merged
+	}
+	return nil
+}
+
+// Report the first field of the config file that doesn't exist in Config, nil if there is none
+func checkUnknownFields(data []byte, path string) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	// Decoded into a scratch value, the real one was already loaded
+	err := decoder.Decode(DefaultConfig())
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return fmt.Errorf("unknown field %s in config file %s", strings.TrimPrefix(err.Error(), "json: unknown field "), path)
+	}
+	return nil
+}
+
+// Name the offending field and its expected type, or the line of a syntax error,
+// instead of the offsets reported by encoding/json
+func describeDecodeError(data []byte, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("invalid value for %q on line %d: expected %s, got %s", typeErr.Field, lineOf(data, typeErr.Offset), typeErr.Type, typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("invalid JSON on line %d: %w", lineOf(data, syntaxErr.Offset), err)
+	default:
+		return err
+	}
+}
+
+// Line number of a byte offset in data, starting at 1
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func loadFromEnv(cfg *Config) {
+	if val := os.Getenv("WG_LISTEN_ADDRESS"); val != "" {
+		cfg.ListenAddress = val
+	} else if val := os.Getenv("PORT"); val != "" {
+		// Set by many PaaS platforms, only the port on all addresses
+		cfg.ListenAddress = ":" + val
+	}
+	if val := os.Getenv("LOG_FORMAT"); val != "" {
+		cfg.LogFormat = val
+	}
+	if val := os.Getenv("WG_METRICS_PATH"); val != "" {
+		cfg.MetricsPath = val
+	}
+	if val := os.Getenv("WG_TLS_CERT_FILE"); val != "" {
+		cfg.TLSCertFile = val
+	}
+	if val := os.Getenv("WG_TLS_KEY_FILE"); val != "" {
+		cfg.TLSKeyFile = val
+	}
+	if val := os.Getenv("WG_TLS_CLIENT_CA_FILE"); val != "" {
+		cfg.TLSClientCAFile = val
+	}
+	if val := os.Getenv("WG_DISABLE_LANDING_PAGE"); val != "" {
+		cfg.DisableLandingPage = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_LANDING_PAGE_HTML"); val != "" {
+		cfg.LandingPageHTML = val
+	}
+	if val := os.Getenv("WG_ENABLE_PPROF"); val != "" {
+		cfg.EnablePprof = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_PPROF_ADDRESS"); val != "" {
+		cfg.PprofAddress = val
+	}
+	if val := os.Getenv("WG_ENABLE_RUNTIME_METRICS"); val != "" {
+		cfg.EnableRuntimeMetrics = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_METRIC_NAMESPACE"); val != "" {
+		cfg.MetricNamespace = val
+	}
+	if val := os.Getenv("WG_NODE_LABEL"); val != "" {
+		cfg.NodeLabel = val
+	}
+	if val := os.Getenv("WG_ENABLE_INTERFACES_JSON"); val != "" {
+		cfg.EnableInterfacesJSON = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_REDACT_PUBLIC_KEYS"); val != "" {
+		cfg.RedactPublicKeys = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_INTERFACES_DENYLIST"); val != "" {
+		cfg.InterfacesDenylist = strings.Split(val, ",")
+		for i := range cfg.InterfacesDenylist {
+			cfg.InterfacesDenylist[i] = strings.TrimSpace(cfg.InterfacesDenylist[i])
+		}
+	}
+	if val := os.Getenv("WG_EXPECTED_INTERFACES"); val != "" {
+		cfg.ExpectedInterfaces = strings.Split(val, ",")
+		for i := range cfg.ExpectedInterfaces {
+			cfg.ExpectedInterfaces[i] = strings.TrimSpace(cfg.ExpectedInterfaces[i])
+		}
+	}
+	if val := os.Getenv("WG_REGEX_MATCH_INTERFACES"); val != "" {
+		cfg.RegexMatchInterfaces = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_REPORT_DENIED_INTERFACES"); val != "" {
+		cfg.ReportDeniedInterfaces = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_COMMAND_PATH"); val != "" {
+		cfg.WGCommandPath = val
+		cfg.WGCommand = nil
+	}
+	if val := os.Getenv("WG_COMMAND"); val != "" {
+		cfg.WGCommand = strings.Fields(val)
+	}
+	if val := os.Getenv("WG_DUMP_FILE"); val != "" {
+		cfg.DumpFile = val
+	}
+	if val := os.Getenv("WG_MAX_CONCURRENCY"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.MaxConcurrency = n
+		} else {
+			slog.Warn("Ignoring invalid WG_MAX_CONCURRENCY", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_COMMAND_RETRIES"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.CommandRetries = n
+		} else {
+			slog.Warn("Ignoring invalid WG_COMMAND_RETRIES", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_CIRCUIT_BREAKER_THRESHOLD"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.CircuitBreakerThreshold = n
+		} else {
+			slog.Warn("Ignoring invalid WG_CIRCUIT_BREAKER_THRESHOLD", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_CIRCUIT_BREAKER_COOLDOWN"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.CircuitBreakerCooldown = Duration(d)
+		} else {
+			slog.Warn("Ignoring invalid WG_CIRCUIT_BREAKER_COOLDOWN", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_OUTPUT_FORMAT"); val != "" {
+		cfg.OutputFormat = val
+	}
+	if val := os.Getenv("WG_STRICT"); val != "" {
+		cfg.StrictMode = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_SHOW_ENDPOINTS"); val != "" {
+		cfg.ShowEndpoints = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_RESOLVE_ENDPOINT_HOSTNAMES"); val != "" {
+		cfg.ResolveEndpointHostnames = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_AGGREGATE_ONLY"); val != "" {
+		cfg.AggregateOnly = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_MAX_PEERS_PER_INTERFACE"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.MaxPeersPerInterface = n
+		} else {
+			slog.Warn("Ignoring invalid WG_MAX_PEERS_PER_INTERFACE", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_ENABLE_EXEMPLARS"); val != "" {
+		cfg.EnableExemplars = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_LABEL_DENYLIST"); val != "" {
+		cfg.LabelDenylist = strings.Split(val, ",")
+		for i := range cfg.LabelDenylist {
+			cfg.LabelDenylist[i] = strings.TrimSpace(cfg.LabelDenylist[i])
+		}
+	}
+	if val := os.Getenv("WG_PEERS_DENYLIST"); val != "" {
+		cfg.PeersDenylist = strings.Split(val, ",")
+		for i := range cfg.PeersDenylist {
+			cfg.PeersDenylist[i] = strings.TrimSpace(cfg.PeersDenylist[i])
+		}
+	}
+	if val := os.Getenv("WG_PEERS_DENYLIST_EXCLUDE_TOTALS"); val != "" {
+		cfg.PeersDenylistExcludeTotals = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_PEER_KEY_LABEL_MODE"); val != "" {
+		cfg.PeerKeyLabelMode = val
+	}
+	if val := os.Getenv("WG_SHOW_ALLOWED_IPS"); val != "" {
+		cfg.ShowAllowedIPs = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_PEER_INFO_ALLOWED_IPS_MAX_LENGTH"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			cfg.PeerInfoAllowedIPsMaxLength = n
+		} else {
+			slog.Warn("Ignoring invalid WG_PEER_INFO_ALLOWED_IPS_MAX_LENGTH", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_TRACKED_SUBNETS"); val != "" {
+		cfg.TrackedSubnets = strings.Split(val, ",")
+		for i := range cfg.TrackedSubnets {
+			cfg.TrackedSubnets[i] = strings.TrimSpace(cfg.TrackedSubnets[i])
+		}
+	}
+	if val := os.Getenv("WG_DETECT_ALLOWED_IP_OVERLAPS"); val != "" {
+		cfg.DetectAllowedIPOverlaps = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_CONFIG_DIR"); val != "" {
+		cfg.ConfigDir = val
+	}
+	if val := os.Getenv("WG_READ_CONFIG_FILES"); val != "" {
+		cfg.ReadConfigFiles = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_READ_SHOWCONF"); val != "" {
+		cfg.ReadShowconf = strings.ToLower(val) == "true" || val == "1"
+	}
+	if val := os.Getenv("WG_HTTP_READ_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.HTTPReadTimeout = Duration(d)
+		} else {
+			slog.Warn("Ignoring invalid WG_HTTP_READ_TIMEOUT", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_HTTP_WRITE_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.HTTPWriteTimeout = Duration(d)
+		} else {
+			slog.Warn("Ignoring invalid WG_HTTP_WRITE_TIMEOUT", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_HTTP_IDLE_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.HTTPIdleTimeout = Duration(d)
+		} else {
+			slog.Warn("Ignoring invalid WG_HTTP_IDLE_TIMEOUT", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_MIN_SCRAPE_INTERVAL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.MinScrapeInterval = Duration(d)
+		} else {
+			slog.Warn("Ignoring invalid WG_MIN_SCRAPE_INTERVAL", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_PEER_UP_THRESHOLD"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.PeerUpThreshold = Duration(d)
+		} else {
+			slog.Warn("Ignoring invalid WG_PEER_UP_THRESHOLD", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_KEEPALIVE_OVERDUE_FACTOR"); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			cfg.KeepaliveOverdueFactor = f
+		} else {
+			slog.Warn("Ignoring invalid WG_KEEPALIVE_OVERDUE_FACTOR", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_MAX_PEER_STALENESS"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			cfg.MaxPeerStaleness = Duration(d)
+		} else {
+			slog.Warn("Ignoring invalid WG_MAX_PEER_STALENESS", "value", val, "error", err)
+		}
+	}
+	if val := os.Getenv("WG_STATE_FILE"); val != "" {
+		cfg.StateFile = val
+	}
+	if val := os.Getenv("WG_INTERFACE_LABELS"); val != "" {
+		if cfg.InterfaceLabels == nil {
+			cfg.InterfaceLabels = make(map[string]map[string]string)
+		}
+		// Replaces the labels the config files set for the same interface
+		for iface, labels := range parseInterfaceLabels(val) {
+			cfg.InterfaceLabels[iface] = labels
+		}
+	}
+	// Config file paths would need a specific format, skipping for now
+}
+
+// Parse the WG_INTERFACE_LABELS format, e.g. "wg0:site=nyc,role=hub;wg1:site=sfo". Malformed
+// entries are logged and skipped, label names are validated later along with the config file ones
+func parseInterfaceLabels(val string) map[string]map[string]string {
+	result := make(map[string]map[string]string)
+	for _, entry := range strings.Split(val, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		iface, pairs, ok := strings.Cut(entry, ":")
+		iface = strings.TrimSpace(iface)
+		if !ok || iface == "" {
+			slog.Warn("Ignoring invalid WG_INTERFACE_LABELS entry, expected interface:name=value,...", "entry", entry)
+			continue
+		}
+
+		labels := make(map[string]string)
+		for _, pair := range strings.Split(pairs, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				slog.Warn("Ignoring invalid WG_INTERFACE_LABELS entry, expected interface:name=value,...", "entry", entry)
+				labels = nil
+				break
+			}
+			labels[name] = strings.TrimSpace(value)
+		}
+		if labels != nil {
+			result[iface] = labels
+		}
+	}
+	return result
+}
+
+
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Build the configuration from config files with the given contents, without any flags
+func loadTestConfig(t *testing.T, contents ...string) (*Config, []string, error) {
+	t.Helper()
+	savedPaths, savedFlags := configFilePaths, applyFlags
+	t.Cleanup(func() {
+		configFilePaths, applyFlags = savedPaths, savedFlags
+	})
+
+	dir := t.TempDir()
+	var paths []string
+	for i, content := range contents {
+		path := filepath.Join(dir, fmt.Sprintf("config%d.json", i))
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	configFilePaths = paths
+	applyFlags = func(cfg *Config) {}
+
+	cfg, err := buildConfig()
+	return cfg, paths, err
+}
+
+func TestInterfaceLabelsMergedAcrossFiles(t *testing.T) {
+	cfg, _, err := loadTestConfig(t,
+		`{"interface_labels": {"wg0": {"site": "fra", "role": "spoke"}, "wg1": {"site": "ams"}}}`,
+		`{"interface_labels": {"wg0": {"role": "hub"}, "wg2": {"site": "lon"}}}`,
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]map[string]string{
+		"wg0": {"site": "fra", "role": "hub"},
+		"wg1": {"site": "ams"},
+		"wg2": {"site": "lon"},
+	}
+	for ifaceName, labels := range want {
+		for name, value := range labels {
+			if got := cfg.InterfaceLabels[ifaceName][name]; got != value {
+				t.Errorf("%s label %s = %q, want %q", ifaceName, name, got, value)
+			}
+		}
+		if len(cfg.InterfaceLabels[ifaceName]) != len(labels) {
+			t.Errorf("%s labels = %v, want %v", ifaceName, cfg.InterfaceLabels[ifaceName], labels)
+		}
+	}
+}
+
+func TestNormalizeListenAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{addr: ":9586", want: ":9586"},
+		{addr: "0.0.0.0:9586", want: "0.0.0.0:9586"},
+		{addr: " [::1]:9586 ", want: "[::1]:9586"},
+		{addr: "unix:/run/wg-exporter.sock", want: "unix:/run/wg-exporter.sock"},
+		{addr: "localhost", wantErr: true},
+		{addr: "9586", wantErr: true},
+		{addr: ":", wantErr: true},
+		{addr: "host:99999", wantErr: true},
+		{addr: "unix:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			cfg := &Config{ListenAddress: tt.addr}
+			err := normalizeListenAddress(cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", cfg.ListenAddress)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.ListenAddress != tt.want {
+				t.Errorf("listen address = %q, want %q", cfg.ListenAddress, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMetricsPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "/metrics"},
+		{path: "/wireguard/metrics"},
+		{path: "", wantErr: true},
+		{path: "metrics", wantErr: true},
+		{path: "/", wantErr: true},
+		{path: "/health", wantErr: true},
+		{path: "/ready", wantErr: true},
+		{path: "/interfaces.json", wantErr: true},
+		{path: "/probe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := validateMetricsPath(tt.path)
+			if tt.wantErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "unknown field", content: "{\n  \"strict_mode\": true,\n  \"listen_adress\": \":9586\"\n}", want: `unknown field "listen_adress"`},
+		{name: "wrong type", content: "{\n  \"max_concurrency\": \"4\"\n}", want: `invalid value for "max_concurrency" on line 2`},
+		{name: "syntax error", content: "{\n  \"log_format\": \"json\",\n}", want: "invalid JSON on line 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, paths, err := loadTestConfig(t, tt.content)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+			if !strings.Contains(err.Error(), paths[0]) {
+				t.Errorf("error %q does not name the file %s", err, paths[0])
+			}
+		})
+	}
+}
+
+func TestParseInterfaceLabels(t *testing.T) {
+	got := parseInterfaceLabels("wg0:site=nyc,role=hub; wg1: site = sfo ;broken;wg2:role;wg3:tier=gold")
+	want := map[string]map[string]string{
+		"wg0": {"site": "nyc", "role": "hub"},
+		"wg1": {"site": "sfo"},
+		"wg3": {"tier": "gold"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("parseInterfaceLabels = %v, want %v", got, want)
+	}
+	for iface, labels := range want {
+		if !maps.Equal(got[iface], labels) {
+			t.Errorf("labels of %s = %v, want %v", iface, got[iface], labels)
+		}
+	}
+}
+
+func TestValidateInterfaceLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]map[string]string
+		wantErr bool
+	}{
+		{name: "valid", labels: map[string]map[string]string{"wg0": {"site": "nyc", "role": "hub"}, "wg-*": {"tier": "gold"}}},
+		{name: "invalid name", labels: map[string]map[string]string{"wg0": {"site-name": "nyc"}}, wantErr: true},
+		{name: "reserved prefix", labels: map[string]map[string]string{"wg0": {"__site": "nyc"}}, wantErr: true},
+		{name: "built-in name", labels: map[string]map[string]string{"wg0": {"peer": "nyc"}}, wantErr: true},
+		{name: "invalid pattern", labels: map[string]map[string]string{"wg[": {"site": "nyc"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateInterfaceLabels(&Config{InterfaceLabels: tt.labels})
+			if tt.wantErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLabelDenylist(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "redactable labels", content: `{"label_denylist": ["endpoint", "bucket", "subnet"]}`},
+		{name: "custom label", content: `{"interface_labels": {"wg0": {"site": "fra"}}, "label_denylist": ["site"]}`},
+		{name: "unknown label", content: `{"label_denylist": ["endpont"]}`, wantErr: true},
+		{name: "node label", content: `{"label_denylist": ["node"]}`, wantErr: true},
+		{name: "version label", content: `{"label_denylist": ["version"]}`, wantErr: true},
+		{name: "target label", content: `{"label_denylist": ["target"]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := loadTestConfig(t, tt.content)
+			if tt.wantErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHandshakeAgeBuckets(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []time.Duration
+		wantErr bool
+	}{
+		{name: "sorted", content: `{"handshake_age_buckets": ["1h", "2m", "10m"]}`, want: []time.Duration{2 * time.Minute, 10 * time.Minute, time.Hour}},
+		{name: "duplicate", content: `{"handshake_age_buckets": ["2m", "2m"]}`, wantErr: true},
+		{name: "duplicate written differently", content: `{"handshake_age_buckets": ["120s", "2m"]}`, wantErr: true},
+		{name: "zero", content: `{"handshake_age_buckets": ["0s", "2m"]}`, wantErr: true},
+		{name: "negative", content: `{"handshake_age_buckets": ["-2m"]}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := loadTestConfig(t, tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", cfg.HandshakeAgeBuckets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []time.Duration
+			for _, bound := range cfg.HandshakeAgeBuckets {
+				got = append(got, time.Duration(bound))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("buckets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactedConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.CommandEnv = map[string]string{"SSHPASS": "secret"}
+	cfg.RemoteTargets = map[string][]string{"hub": {"sshpass", "-p", "secret", "ssh", "hub", "wg"}}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	logger.Info("Full Configuration dump", "config", redactedConfig(cfg))
+
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("credentials in the config dump: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "SSHPASS") || !strings.Contains(buf.String(), "sshpass") {
+		t.Errorf("variable names or executables missing from the config dump: %s", buf.String())
+	}
+	if cfg.CommandEnv["SSHPASS"] != "secret" || len(cfg.RemoteTargets["hub"]) != 6 {
+		t.Error("redacting modified the configuration")
+	}
+}
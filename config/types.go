@@ -1,24 +1,207 @@
-package config
-
-type Config struct {
-	ListenAddress     string            `json:"listen_address"`
-	MetricsPath       string            `json:"metrics_path"`
-	InterfacesDenylist []string         `json:"interfaces_denylist"`
-	WGCommandPath     string            `json:"wg_command_path"`
-	ShowEndpoints     bool              `json:"show_endpoints"`
-	ReadConfigFiles   bool              `json:"read_config_files"` // Enable reading WireGuard config files for display names
-	ConfigFilePaths   map[string]string `json:"config_file_paths"` // Map of interface name to config file path
-}
-
-func DefaultConfig() *Config {
-	return &Config{
-		ListenAddress:     ":9586",
-		MetricsPath:       "/metrics",
-		InterfacesDenylist: []string{},
-		WGCommandPath:     "wg",
-		ShowEndpoints:     true,
-		ReadConfigFiles:   true, // Enable by default
-		ConfigFilePaths:   make(map[string]string),
-	}
-}
-
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	ExpandEnv         bool              `json:"expand_env"` // Expand $VAR and ${VAR} in string values of the config files
+	ListenAddress     string            `json:"listen_address"`
+	TLSCertFile       string            `json:"tls_cert_file"` // Serve HTTPS with this certificate, empty for plain HTTP
+	TLSKeyFile        string            `json:"tls_key_file"`
+	TLSClientCAFile   string            `json:"tls_client_ca_file"` // Require client certificates signed by these CAs (mutual TLS)
+	LogFormat         string            `json:"log_format"` // "text" or "json"
+	MetricsPath       string            `json:"metrics_path"`
+	DisableLandingPage bool             `json:"disable_landing_page"` // Answer 404 on "/" instead of the landing page
+	LandingPageHTML   string            `json:"landing_page_html"` // Custom HTML body of the landing page, empty for the plain text default
+	HTTPReadTimeout   Duration          `json:"http_read_timeout"`
+	HTTPWriteTimeout  Duration          `json:"http_write_timeout"` // Must cover the whole scrape, raise it for large outputs on slow hosts
+	HTTPIdleTimeout   Duration          `json:"http_idle_timeout"`
+	EnablePprof       bool              `json:"enable_pprof"` // Serve net/http/pprof on PprofAddress
+	PprofAddress      string            `json:"pprof_address"` // Kept apart from ListenAddress so profiles are not exposed with the metrics
+	EnableRuntimeMetrics bool           `json:"enable_runtime_metrics"` // Report the exporter's own goroutines and heap size
+	MetricNamespace   string            `json:"metric_namespace"` // Prefix of all metric names
+	EnableInterfacesJSON bool           `json:"enable_interfaces_json"` // Serve the parsed interfaces and peers on /interfaces.json, exposes the topology
+	RedactPublicKeys  bool              `json:"redact_public_keys"` // Shorten public keys in /interfaces.json
+	NodeLabel         string            `json:"node_label"` // Value of the node label on every metric, "auto" uses the hostname, empty disables
+	InterfacesDenylist []string         `json:"interfaces_denylist"`
+	RegexMatchInterfaces bool           `json:"regex_match_interfaces"` // Treat denylist entries as regular expressions
+	InterfacesDenylistPatterns []*regexp.Regexp `json:"-"` // Compiled denylist, set at load when RegexMatchInterfaces is enabled
+	ReportDeniedInterfaces bool         `json:"report_denied_interfaces"` // Report the names of denylisted interfaces, without running wg for them
+	ExpectedInterfaces []string         `json:"expected_interfaces"` // Interfaces reported as down when wg doesn't list them
+	WGCommandPath     string            `json:"wg_command_path"`
+	WGCommand         []string          `json:"wg_command"` // argv run before the wg arguments, e.g. a wrapper like nsenter. Defaults to WGCommandPath alone
+	CommandEnv        map[string]string `json:"command_env"` // Variables set for the wg command on top of the inherited environment, e.g. PATH
+	MaxConcurrency    int               `json:"max_concurrency"` // Maximum number of interfaces fetched at once
+	CommandRetries    int               `json:"command_retries"` // Retries when a wg command fails to execute, 0 disables
+	CircuitBreakerThreshold int         `json:"circuit_breaker_threshold"` // Consecutive failures after which an interface is skipped for CircuitBreakerCooldown, 0 disables
+	CircuitBreakerCooldown Duration     `json:"circuit_breaker_cooldown"` // How long a consistently failing interface is skipped before it is tried again
+	OutputFormat      string            `json:"output_format"` // wg output to parse: "dump" or "human"
+	DumpFile          string            `json:"dump_file"` // Read one interface from a captured "wg show <interface> dump" instead of running wg, "-" for stdin
+	StrictMode        bool              `json:"strict_mode"` // Fail at startup instead of warning when the wg command is unusable, a config file has unknown fields or a config file path is not readable
+	ShowEndpoints     bool              `json:"show_endpoints"`
+	ResolveEndpointHostnames bool       `json:"resolve_endpoint_hostnames"` // Reverse DNS lookup of endpoint IPs for the endpoint_hostname label, cached
+	AggregateOnly     bool              `json:"aggregate_only"` // Only export interface-level metrics, no per-peer series
+	MaxPeersPerInterface int            `json:"max_peers_per_interface"` // Peer metrics only for this many peers per interface, the most recent handshakes first, 0 disables
+	EnableExemplars   bool              `json:"enable_exemplars"` // Attach exemplars to the peer byte counters, served in the OpenMetrics format
+	LabelDenylist     []string          `json:"label_denylist"` // Labels whose values are hidden, hashed where they tell series apart
+	PeersDenylist     []string          `json:"peers_denylist"` // Public keys, or prefixes of them, of peers without peer-level metrics
+	PeersDenylistExcludeTotals bool     `json:"peers_denylist_exclude_totals"` // Also leave denylisted peers out of the interface-level metrics
+	PeerKeyLabelMode  string            `json:"peer_key_label_mode"` // How public keys appear in the peer label: "full", "short" or "hash"
+	ShowAllowedIPs    bool              `json:"show_allowed_ips"` // Export one series per allowed IP, can be high-cardinality
+	TrackedSubnets    []string          `json:"tracked_subnets"` // Parent subnets whose peers are counted, e.g. one /24 per site
+	TrackedSubnetNets []*net.IPNet      `json:"-"` // Parsed TrackedSubnets, set at load
+	DetectAllowedIPOverlaps bool        `json:"detect_allowed_ip_overlaps"` // Compare allowed IPs of all peer pairs, O(n^2) in the number of allowed IPs
+	PeerInfoAllowedIPsMaxLength int     `json:"peer_info_allowed_ips_max_length"` // Truncate the allowed_ips label of wireguard_peer_info, 0 disables
+	ReadConfigFiles   bool              `json:"read_config_files"` // Enable reading WireGuard config files for display names
+	ReadShowconf      bool              `json:"read_showconf"` // Enrich peers from "wg showconf", for when config files are not readable
+	ConfigDir         string            `json:"config_dir"` // Directory of the <interface>.conf files, used for interfaces without an entry in ConfigFilePaths
+	ConfigFilePaths   map[string]string `json:"config_file_paths"` // Map of interface name to config file path
+	InterfaceLabels   map[string]map[string]string `json:"interface_labels"` // Map of interface name to custom labels added to its interface and peer metrics
+	InterfaceAliases  map[string]string `json:"interface_aliases"` // Map of interface name to the value used in the interface label
+	RemoteTargets     map[string][]string `json:"remote_targets"` // Map of target name to the wg command reaching it, e.g. over SSH, served on /probe?target=<name>
+	MetricHelpOverrides map[string]string `json:"metric_help_overrides"` // Map of metric name, without the namespace, to a custom help text
+	MinScrapeInterval Duration          `json:"min_scrape_interval"` // Serve the previous scrape again when scraped sooner than this, 0 disables
+	PeerUpThreshold   Duration          `json:"peer_up_threshold"` // A peer is active when its latest handshake is at most this old
+	KeepaliveOverdueFactor float64      `json:"keepalive_overdue_factor"` // Multiple of the persistent keepalive, on top of the rekey interval, after which a handshake is overdue
+	MaxPeerStaleness  Duration          `json:"max_peer_staleness"` // Skip peer metrics of peers without a handshake within this duration, 0 disables
+	StateFile         string            `json:"state_file"` // Where byte counter totals are persisted across restarts, empty disables
+	HandshakeAgeBuckets []Duration      `json:"handshake_age_buckets"` // Upper bounds for the handshake age peer buckets
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		ExpandEnv:         false,
+		ListenAddress:     ":9586",
+		LogFormat:         "text",
+		MetricsPath:       "/metrics",
+		DisableLandingPage: false,
+		LandingPageHTML:   "",
+		HTTPReadTimeout:   Duration(10 * time.Second),
+		HTTPWriteTimeout:  Duration(10 * time.Second),
+		HTTPIdleTimeout:   Duration(120 * time.Second),
+		EnablePprof:       false,
+		PprofAddress:      "localhost:6060",
+		EnableRuntimeMetrics: false,
+		MetricNamespace:   "wireguard",
+		EnableInterfacesJSON: false,
+		RedactPublicKeys:  false,
+		NodeLabel:         "",
+		InterfacesDenylist: []string{},
+		RegexMatchInterfaces: false,
+		ReportDeniedInterfaces: false,
+		ExpectedInterfaces: []string{},
+		WGCommandPath:     "wg",
+		MaxConcurrency:    runtime.NumCPU(),
+		CommandRetries:    0,
+		CircuitBreakerThreshold: 0,
+		CircuitBreakerCooldown: Duration(5 * time.Minute),
+		OutputFormat:      "dump",
+		StrictMode:        false,
+		ShowEndpoints:     true,
+		ResolveEndpointHostnames: false,
+		AggregateOnly:     false,
+		MaxPeersPerInterface: 0,
+		EnableExemplars:   false,
+		LabelDenylist:     []string{},
+		PeersDenylist:     []string{},
+		PeerKeyLabelMode:  "full",
+		ShowAllowedIPs:    false,
+		TrackedSubnets:    []string{},
+		PeerInfoAllowedIPsMaxLength: 256,
+		ReadConfigFiles:   true, // Enable by default
+		ReadShowconf:      false,
+		ConfigDir:         "/etc/wireguard",
+		CommandEnv:        make(map[string]string),
+		ConfigFilePaths:   make(map[string]string),
+		InterfaceLabels:   make(map[string]map[string]string),
+		InterfaceAliases:  make(map[string]string),
+		RemoteTargets:     make(map[string][]string),
+		MetricHelpOverrides: make(map[string]string),
+		// WireGuard renews the session every 2 minutes while there is traffic
+		PeerUpThreshold:   Duration(3 * time.Minute),
+		KeepaliveOverdueFactor: 3,
+		HandshakeAgeBuckets: []Duration{
+			Duration(2 * time.Minute),
+			Duration(10 * time.Minute),
+			Duration(time.Hour),
+		},
+	}
+}
+
+// Custom label names used by any interface, sorted. Interfaces that don't define
+// one of them get an empty value, so all series of a metric share the same label names
+func (c *Config) CustomLabelNames() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, labels := range c.InterfaceLabels {
+		for name := range labels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Custom labels of an interface. Keys of InterfaceLabels can be glob patterns like "*" or
+// "wg-client-*", matching patterns are applied in sorted order and an entry for the exact
+// name overrides them label by label
+func (c *Config) InterfaceLabelsFor(ifaceName string) map[string]string {
+	var patterns []string
+	for key := range c.InterfaceLabels {
+		if key != ifaceName && IsGlobPattern(key) {
+			if matched, _ := path.Match(key, ifaceName); matched {
+				patterns = append(patterns, key)
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return c.InterfaceLabels[ifaceName]
+	}
+	sort.Strings(patterns)
+
+	labels := make(map[string]string)
+	for _, key := range append(patterns, ifaceName) {
+		for name, value := range c.InterfaceLabels[key] {
+			labels[name] = value
+		}
+	}
+	return labels
+}
+
+// Whether an InterfaceLabels key is a glob pattern, interface names never contain these characters
+func IsGlobPattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+// Duration is a time.Duration that reads from JSON as a string like "2m" or "1h30m"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"2m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
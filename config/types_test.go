@@ -0,0 +1,63 @@
+package config
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestInterfaceLabelsFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]map[string]string
+		iface  string
+		want   map[string]string
+	}{
+		{
+			name:   "wildcard only",
+			labels: map[string]map[string]string{"*": {"site": "fra"}},
+			iface:  "wg0",
+			want:   map[string]string{"site": "fra"},
+		},
+		{
+			name:   "explicit only",
+			labels: map[string]map[string]string{"wg0": {"site": "fra"}, "wg1": {"site": "ams"}},
+			iface:  "wg1",
+			want:   map[string]string{"site": "ams"},
+		},
+		{
+			name:   "no match",
+			labels: map[string]map[string]string{"wg-client-*": {"role": "client"}},
+			iface:  "wg0",
+			want:   map[string]string{},
+		},
+		{
+			name: "explicit overrides patterns label by label",
+			labels: map[string]map[string]string{
+				"*":           {"site": "fra", "role": "any"},
+				"wg-client-*": {"role": "client"},
+				"wg-client-1": {"site": "ams"},
+			},
+			iface: "wg-client-1",
+			want:  map[string]string{"site": "ams", "role": "client"},
+		},
+		{
+			name: "overlapping patterns applied in sorted order",
+			labels: map[string]map[string]string{
+				"wg-*":        {"role": "generic"},
+				"wg-client-*": {"role": "client"},
+			},
+			iface: "wg-client-2",
+			want:  map[string]string{"role": "client"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{InterfaceLabels: tt.labels}
+			got := cfg.InterfaceLabelsFor(tt.iface)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("InterfaceLabelsFor(%q) = %v, want %v", tt.iface, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,101 +1,583 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log/slog"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
-	"wireguard-exporter-go/config"
-	"wireguard-exporter-go/wireguard"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-)
-
-func main() {
-	level := slog.LevelInfo // Default log level
-	varslogLevel := os.Getenv("LOG_LEVEL")
-	if varslogLevel == "debug" {
-		level = slog.LevelDebug
-	}
-	slog.Info("Log level", "level", level)
-
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: level,
-	}))
-	slog.SetDefault(logger)
-
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		slog.Error("Failed to load configuration", "error", err)
-		os.Exit(1)
-	}
-
-	collector := wireguard.NewCollector(cfg)
-
-	if err := prometheus.Register(collector); err != nil {
-		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
-			// If already registered, unregister and re-register
-			prometheus.Unregister(are.ExistingCollector)
-			prometheus.Register(collector)
-		} else {
-			slog.Error("Failed to register collector", "error", err)
-			os.Exit(1)
-		}
-	}
-
-	mux := http.NewServeMux()
-
-	mux.Handle(cfg.MetricsPath, promhttp.Handler())
-
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "WireGuard Prometheus Exporter\n")
-		fmt.Fprintf(w, "Metrics endpoint: %s\n", cfg.MetricsPath)
-	})
-
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "OK\n")
-	})
-
-	server := &http.Server{
-		Addr:         cfg.ListenAddress,
-		Handler:      mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
-	}
-
-	// Start server in goroutine
-	go func() {
-		slog.Info("Starting WireGuard Prometheus exporter", "address", cfg.ListenAddress, "path", cfg.MetricsPath)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("Failed to start server", "error", err)
-			os.Exit(1)
-		}
-	}()
-
-	// Wait for interrupt signal for graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	slog.Info("Shutting down server...")
-
-	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := server.Shutdown(ctx); err != nil {
-		slog.Error("Server forced to shutdown", "error", err)
-		os.Exit(1)
-	}
-
-	slog.Info("Server exited")
-}
-
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"wireguard-exporter-go/config"
+	"wireguard-exporter-go/metrics"
+	"wireguard-exporter-go/wireguard"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.revision=..."
+var (
+	version  = "dev"
+	revision = "unknown"
+)
+
+// Parsed along with the configuration flags, it is a mode of the program rather than a setting
+var once = flag.Bool("once", false, "Collect the metrics once, print them on stdout in the Prometheus text format and exit, e.g. for the Pushgateway")
+
+func main() {
+	level := slog.LevelInfo // Default log level
+	varslogLevel := os.Getenv("LOG_LEVEL")
+	if varslogLevel == "debug" {
+		level = slog.LevelDebug
+	}
+	slog.Info("Log level", "level", level)
+
+	// With -once stdout holds the metrics, so every log message goes to stderr, also the ones
+	// logged before the flags are parsed
+	logOutput := io.Writer(os.Stdout)
+	if onceRequested(os.Args[1:]) {
+		logOutput = os.Stderr
+	}
+
+	logFormat := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	slog.SetDefault(newLogger(logFormat, level, logOutput))
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// The -log-format flag is only known once the configuration is loaded
+	if cfg.LogFormat != logFormat {
+		slog.SetDefault(newLogger(cfg.LogFormat, level, logOutput))
+	}
+
+	var collector *wireguard.Collector
+	if cfg.DumpFile != "" {
+		// Offline mode, wg is never run
+		runner, err := wireguard.NewDumpFileRunner(cfg.DumpFile)
+		if err != nil {
+			slog.Error("Failed to open dump file", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Reading WireGuard data from dump file", "path", cfg.DumpFile)
+		collector = wireguard.NewCollectorWithRunner(cfg, runner)
+	} else {
+		// Check the wg command up front, otherwise a missing binary only shows up on the first scrape
+		wgPath, err := wireguard.ResolveWGCommand(cfg.WGCommand)
+		if err != nil {
+			if cfg.StrictMode {
+				slog.Error("WireGuard command is not usable, exiting (strict mode)", "error", err)
+				os.Exit(1)
+			}
+			slog.Warn("WireGuard command is not usable, scrapes will fail until it is installed", "error", err)
+		} else {
+			slog.Info("Using WireGuard command", "path", wgPath)
+		}
+
+		collector = wireguard.NewCollector(cfg)
+	}
+
+	slog.Info("WireGuard Prometheus exporter", "version", version, "revision", revision)
+
+	if err := prometheus.Register(metrics.NewBuildInfo(cfg.MetricNamespace, cfg.NodeLabel, version, revision)); err != nil {
+		slog.Error("Failed to register build info", "error", err)
+		os.Exit(1)
+	}
+
+	// The collector is registered per scrape (see metricsHandler), check its descriptors once here
+	if err := prometheus.NewRegistry().Register(collector); err != nil {
+		slog.Error("Failed to register collector", "error", err)
+		os.Exit(1)
+	}
+
+	if *once {
+		if err := writeMetricsOnce(collector, os.Stdout); err != nil {
+			slog.Error("Failed to collect metrics", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	mux := http.NewServeMux()
+
+	// Scrapes sooner than MinScrapeInterval get the previous result instead of running wg again
+	cachedCollector := wireguard.NewCachedCollector(collector)
+	mux.Handle(cfg.MetricsPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, conditionalHandler(cachedCollector, metricsHandler(cachedCollector, cfg.EnableExemplars))))
+
+	mux.Handle("/", landingPageHandler(cfg))
+
+	// Liveness only, /ready checks that wg works
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "OK\n")
+	})
+
+	mux.Handle("/ready", readyHandler(collector))
+
+	// Off by default, the JSON shows the whole VPN topology
+	if cfg.EnableInterfacesJSON {
+		mux.Handle("/interfaces.json", interfacesHandler(collector))
+	}
+
+	// Multi-target pattern, Prometheus passes the remote host to scrape as a parameter
+	targets := wireguard.NewTargetCollectors(cfg)
+	if len(cfg.RemoteTargets) > 0 {
+		mux.Handle("/probe", probeHandler(targets, cfg.EnableExemplars))
+	}
+
+	server := &http.Server{
+		Addr:         cfg.ListenAddress,
+		Handler:      mux,
+		ReadTimeout:  time.Duration(cfg.HTTPReadTimeout),
+		WriteTimeout: time.Duration(cfg.HTTPWriteTimeout),
+		IdleTimeout:  time.Duration(cfg.HTTPIdleTimeout),
+	}
+
+	if cfg.TLSCertFile != "" {
+		tlsConfig, err := newTLSConfig(cfg.TLSClientCAFile)
+		if err != nil {
+			slog.Error("Failed to configure TLS", "error", err)
+			os.Exit(1)
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	listener, socketPath, err := listen(cfg.ListenAddress)
+	if err != nil {
+		slog.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	}
+
+	// Start server in goroutine
+	go func() {
+		slog.Info("Starting WireGuard Prometheus exporter", "address", cfg.ListenAddress, "path", cfg.MetricsPath, "tls", cfg.TLSCertFile != "", "client_certs", cfg.TLSClientCAFile != "")
+		var err error
+		if cfg.TLSCertFile != "" {
+			err = server.ServeTLS(listener, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Failed to start server", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Profiling runs on its own server so it is never exposed alongside the metrics
+	var pprofServer *http.Server
+	if cfg.EnablePprof {
+		pprofServer = newPprofServer(cfg.PprofAddress)
+		go func() {
+			slog.Warn("Serving pprof profiling endpoints", "address", cfg.PprofAddress)
+			if err := pprofServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Failed to start pprof server", "error", err)
+			}
+		}()
+	}
+
+	// SIGHUP reloads the configuration, the server keeps running
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	// Wait for interrupt signal for graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	for waiting := true; waiting; {
+		select {
+		case <-reload:
+			cfg = reloadConfig(cfg, collector)
+			targets.SetConfig(cfg)
+		case <-quit:
+			waiting = false
+		}
+	}
+
+	slog.Info("Shutting down server...")
+
+	// Graceful shutdown with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if pprofServer != nil {
+		pprofServer.Shutdown(ctx)
+	}
+
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Server forced to shutdown", "error", err)
+		os.Exit(1)
+	}
+
+	if socketPath != "" {
+		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove Unix socket", "path", socketPath, "error", err)
+		}
+	}
+
+	slog.Info("Server exited")
+}
+
+
+// Serve the landing page on "/", a custom HTML body, or 404 when it is disabled. cfg is
+// replaced on reload, so the page is fixed at startup like the metrics path it links to
+func landingPageHandler(cfg *config.Config) http.Handler {
+	if cfg.DisableLandingPage {
+		return http.NotFoundHandler()
+	}
+
+	metricsPath := cfg.MetricsPath
+	landingPageHTML := cfg.LandingPageHTML
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if landingPageHTML != "" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, landingPageHTML)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "WireGuard Prometheus Exporter\n")
+		fmt.Fprintf(w, "Metrics endpoint: %s\n", metricsPath)
+	})
+}
+
+// Collect once and write the same metrics as a scrape in the Prometheus text format.
+// Metrics gathered before an error are still written
+func writeMetricsOnce(collector *wireguard.Collector, w io.Writer) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collector.WithContext(context.Background())); err != nil {
+		return err
+	}
+
+	families, gatherErr := prometheus.Gatherers{prometheus.DefaultGatherer, registry}.Gather()
+
+	encoder := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+	return gatherErr
+}
+
+// Serve the default registry (Go and process metrics) together with the WireGuard metrics.
+// The collector is bound to the request context, so wg commands of a canceled or timed out
+// scrape are killed instead of piling up. Exemplars are only part of the OpenMetrics format,
+// which is offered to scrapers asking for it when openMetrics is set
+func metricsHandler(collector interface {
+	WithContext(ctx context.Context) prometheus.Collector
+}, openMetrics bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(collector.WithContext(ctx)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+		promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{EnableOpenMetrics: openMetrics}).ServeHTTP(w, r)
+	})
+}
+
+// Serve the metrics of one remote target, the multi-target exporter pattern. Only the
+// target's WireGuard metrics are returned, with a target label, and only configured
+// targets can be probed, the parameter never ends up in a command
+func probeHandler(targets *wireguard.TargetCollectors, openMetrics bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		collector, exists := targets.Get(target)
+		if !exists {
+			http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registerer := prometheus.WrapRegistererWith(prometheus.Labels{"target": target}, registry)
+		if err := registerer.Register(collector.WithContext(ctx)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: openMetrics}).ServeHTTP(w, r)
+	})
+}
+
+// Context of a scrape request, honoring the scrape timeout Prometheus sends along with it
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); header != "" {
+		if seconds, err := strconv.ParseFloat(header, 64); err == nil && seconds > 0 {
+			return context.WithTimeout(r.Context(), time.Duration(seconds*float64(time.Second)))
+		}
+	}
+	return context.WithCancel(r.Context())
+}
+
+// Serve the parsed interfaces and peers as JSON, for debugging and non-Prometheus tools
+func interfacesHandler(collector *wireguard.Collector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		interfaces, err := collector.Interfaces(r.Context())
+		if err != nil {
+			slog.Error("Failed to list interfaces", "error", err)
+			http.Error(w, "failed to list interfaces", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(interfaces); err != nil {
+			slog.Error("Failed to write interfaces JSON", "error", err)
+		}
+	})
+}
+
+// How long a readiness result is reused, so frequent probes don't run wg every time
+const readyCacheTTL = 5 * time.Second
+
+// Answer 200 when wg can list the interfaces and 503 otherwise
+func readyHandler(collector *wireguard.Collector) http.Handler {
+	var mu sync.Mutex
+	var checked time.Time
+	var lastErr error
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if time.Since(checked) >= readyCacheTTL {
+			// Not bound to the request, a canceled probe must not be cached as a failure
+			lastErr = collector.Ready(context.Background())
+			checked = time.Now()
+			if lastErr != nil {
+				slog.Warn("Readiness check failed", "error", lastErr)
+			}
+		}
+		err := lastErr
+		mu.Unlock()
+
+		if err != nil {
+			http.Error(w, "wg is not usable", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "OK\n")
+	})
+}
+
+// Add ETag and Last-Modified headers based on the collection time while the cached metrics
+// are served, and answer 304 Not Modified to scrapers that already have them
+func conditionalHandler(cached *wireguard.CachedCollector, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if lastScrape, fresh := cached.CachedSince(); fresh && notModified(r, lastScrape) {
+			setCacheHeaders(w, lastScrape)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		// The headers are only known once the metrics were collected, right before the response is written
+		next.ServeHTTP(&cacheHeaderWriter{ResponseWriter: w, cached: cached}, r)
+	})
+}
+
+// Whether the conditional headers of the request match metrics collected at lastScrape
+func notModified(r *http.Request, lastScrape time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return match == "*" || strings.Contains(match, etag(lastScrape))
+	}
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+		return !lastScrape.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// Weak, since promhttp gzips the body depending on Accept-Encoding and the bytes differ
+// between the encodings of the same scrape
+func etag(lastScrape time.Time) string {
+	return fmt.Sprintf(`W/"%x"`, lastScrape.UnixNano())
+}
+
+func setCacheHeaders(w http.ResponseWriter, lastScrape time.Time) {
+	w.Header().Set("ETag", etag(lastScrape))
+	w.Header().Set("Last-Modified", lastScrape.UTC().Format(http.TimeFormat))
+	// Keeps caches in between from serving a gzipped body to a client that can't read it
+	w.Header().Set("Vary", "Accept-Encoding")
+}
+
+// Response writer that sets the cache headers when the response starts, if the metrics
+// just collected are going to be cached
+type cacheHeaderWriter struct {
+	http.ResponseWriter
+	cached      *wireguard.CachedCollector
+	wroteHeader bool
+}
+
+func (w *cacheHeaderWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if lastScrape, fresh := w.cached.CachedSince(); fresh && status == http.StatusOK {
+			setCacheHeaders(w.ResponseWriter, lastScrape)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheHeaderWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// TLS settings of the metrics server. With a client CA file, scrapers must present a
+// certificate signed by one of its CAs (mutual TLS)
+func newTLSConfig(clientCAFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if clientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in client CA file %s", clientCAFile)
+	}
+
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// Create the server for the net/http/pprof handlers
+func newPprofServer(address string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return &http.Server{
+		Addr:    address,
+		Handler: mux,
+	}
+}
+
+// Create a logger writing to w, as JSON when format is "json" and as text otherwise
+func newLogger(format string, level slog.Level, w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level: level,
+	}
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Whether -once is among the arguments, checked before the flags are parsed to pick the log output
+func onceRequested(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "-once", "--once", "-once=true", "--once=true", "-once=1", "--once=1":
+			return true
+		}
+	}
+	return false
+}
+
+// Open the listener for the given address, a TCP host:port or "unix:<path>".
+// For Unix sockets a stale socket file is removed first and its path is returned for cleanup
+func listen(address string) (net.Listener, string, error) {
+	socketPath, isUnix := strings.CutPrefix(address, "unix:")
+	if !isUnix {
+		listener, err := net.Listen("tcp", address)
+		return listener, "", err
+	}
+
+	// Remove a socket left behind by a previous run, but never a regular file
+	if info, err := os.Lstat(socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, "", fmt.Errorf("%s exists and is not a Unix socket", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return nil, "", fmt.Errorf("failed to remove stale Unix socket %s: %w", socketPath, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	return listener, socketPath, err
+}
+
+// Reload the configuration and hand it to the collector. Settings bound at startup
+// (listen address, metrics path, metric namespace, node label, HTTP timeouts, TLS, dump file) are kept and need a restart to change.
+// On error the current configuration stays in place
+func reloadConfig(current *config.Config, collector *wireguard.Collector) *config.Config {
+	slog.Info("Received SIGHUP, reloading configuration")
+
+	next, err := config.ReloadConfig()
+	if err != nil {
+		slog.Error("Failed to reload configuration, keeping the current one", "error", err)
+		return current
+	}
+
+	if next.ListenAddress != current.ListenAddress || next.MetricsPath != current.MetricsPath || next.MetricNamespace != current.MetricNamespace || next.NodeLabel != current.NodeLabel || next.DumpFile != current.DumpFile || !maps.Equal(next.CommandEnv, current.CommandEnv) || next.EnableExemplars != current.EnableExemplars || !maps.Equal(next.MetricHelpOverrides, current.MetricHelpOverrides) {
+		slog.Warn("Listen address, metrics path, metric namespace, node label, dump file, command environment, exemplar and metric help changes require a restart, keeping the current values")
+		next.ListenAddress = current.ListenAddress
+		next.MetricsPath = current.MetricsPath
+		next.MetricNamespace = current.MetricNamespace
+		next.NodeLabel = current.NodeLabel
+		next.DumpFile = current.DumpFile
+		next.CommandEnv = current.CommandEnv
+		next.EnableExemplars = current.EnableExemplars
+		next.MetricHelpOverrides = current.MetricHelpOverrides
+	}
+
+	slog.Info("Configuration before reload",
+		"interfaces_denylist", current.InterfacesDenylist,
+		"wg_command", current.WGCommand,
+		"output_format", current.OutputFormat,
+		"show_endpoints", current.ShowEndpoints,
+		"show_allowed_ips", current.ShowAllowedIPs,
+		"read_config_files", current.ReadConfigFiles)
+	slog.Info("Configuration after reload",
+		"interfaces_denylist", next.InterfacesDenylist,
+		"wg_command", next.WGCommand,
+		"output_format", next.OutputFormat,
+		"show_endpoints", next.ShowEndpoints,
+		"show_allowed_ips", next.ShowAllowedIPs,
+		"read_config_files", next.ReadConfigFiles)
+
+	collector.SetConfig(next)
+	return next
+}
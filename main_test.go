@@ -0,0 +1,72 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wireguard-exporter-go/config"
+	"wireguard-exporter-go/wireguard"
+)
+
+// Answers wg commands with canned output, keyed by the space-joined arguments
+type fakeRunner map[string]string
+
+func (f fakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, exists := f[strings.Join(args, " ")]
+	if !exists {
+		return nil, fmt.Errorf("unexpected command %s %s", name, strings.Join(args, " "))
+	}
+	return []byte(output), nil
+}
+
+func TestConditionalHandlerGzip(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.WGCommand = []string{"wg"}
+	cfg.ReadConfigFiles = false
+	cfg.MinScrapeInterval = config.Duration(time.Minute)
+
+	runner := fakeRunner{
+		"show interfaces": "wg0\n",
+		"show wg0 dump":   "PRIV\tPUB0\t51820\toff\n",
+	}
+	cached := wireguard.NewCachedCollector(wireguard.NewCollectorWithRunner(cfg, runner))
+	handler := conditionalHandler(cached, metricsHandler(cached, false))
+
+	// The first scrape collects, the second one is served from the cache with the cache headers
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("scrape %d: status %d", i, rec.Code)
+		}
+		if encoding := rec.Header().Get("Content-Encoding"); encoding != "gzip" {
+			t.Fatalf("scrape %d: Content-Encoding = %q, want gzip", i, encoding)
+		}
+
+		reader, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("scrape %d: body is not gzipped: %v", i, err)
+		}
+		body, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("scrape %d: failed to decode body: %v", i, err)
+		}
+		if !strings.Contains(string(body), `wireguard_peers_total{interface="wg0"} 0`) {
+			t.Errorf("scrape %d: body without the WireGuard metrics:\n%s", i, body)
+		}
+
+		if i == 1 && (rec.Header().Get("ETag") == "" || rec.Header().Get("Vary") != "Accept-Encoding") {
+			t.Errorf("cached scrape without cache headers: %v", rec.Header())
+		}
+	}
+}
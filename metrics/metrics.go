@@ -1,88 +1,651 @@
-package metrics
-
-import (
-	"github.com/prometheus/client_golang/prometheus"
-)
-
-// Metric descriptors
-var (
-	PeersTotal = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_peers_total",
-			Help: "Number of configured peers per WireGuard interface",
-		},
-		[]string{"interface"},
-	)
-
-	PeerLatestHandshakeSeconds = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_peer_latest_handshake_seconds",
-			Help: "Unix timestamp of the latest handshake per peer",
-		},
-		[]string{"interface", "peer"},
-	)
-
-	PeerHandshakeAgeSeconds = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_peer_handshake_age_seconds",
-			Help: "Age in seconds of the latest handshake per peer",
-		},
-		[]string{"interface", "peer"},
-	)
-
-	// Note: Using gauge instead of counter since WireGuard provides absolute values
-	PeerBytesSent = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_peer_bytes_sent",
-			Help: "Total bytes sent to peer",
-		},
-		[]string{"interface", "peer"},
-	)
-
-	// Note: Using gauge instead of counter since WireGuard provides absolute values
-	PeerBytesReceived = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_peer_bytes_received",
-			Help: "Total bytes received from peer",
-		},
-		[]string{"interface", "peer"},
-	)
-
-	InterfaceListeningPort = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_interface_listening_port",
-			Help: "Listening port of the WireGuard interface",
-		},
-		[]string{"interface"},
-	)
-
-	PeerEndpoint = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_peer_endpoint",
-			Help: "Peer endpoint information (1 if endpoint exists, 0 otherwise)",
-		},
-		[]string{"interface", "peer", "endpoint"},
-	)
-
-	PeerAllowedIPsCount = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "wireguard_peer_allowed_ips_count",
-			Help: "Number of allowed IPs per peer",
-		},
-		[]string{"interface", "peer"},
-	)
-)
-
-func AllMetrics() []prometheus.Collector {
-	return []prometheus.Collector{
-		PeersTotal,
-		PeerLatestHandshakeSeconds,
-		PeerHandshakeAgeSeconds,
-		PeerBytesSent,
-		PeerBytesReceived,
-		InterfaceListeningPort,
-		PeerEndpoint,
-		PeerAllowedIPsCount,
-	}
-}
-
+package metrics
+
+import (
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds one set of metric vectors. The collector builds a new set for every
+// scrape, so scrapes and collectors never share series
+type Metrics struct {
+	ToolInfo                   *prometheus.GaugeVec
+	ExporterUptimeSeconds      prometheus.Gauge
+	ExporterGoroutines         *prometheus.GaugeVec
+	ExporterMemoryBytes        *prometheus.GaugeVec
+	InterfacesDiscovered       prometheus.Gauge
+	InterfacesFiltered         prometheus.Gauge
+	InterfaceDown              *prometheus.GaugeVec
+	InterfaceDenied            *prometheus.GaugeVec
+	DuplicatePeerKeys          prometheus.Gauge
+	InterfacePortConflicts     prometheus.Gauge
+	PeersTotal                 *prometheus.GaugeVec
+	PeerLatestHandshakeSeconds *prometheus.GaugeVec
+	PeerHandshakeAgeSeconds    *prometheus.GaugeVec
+	PeerBytesSent              *prometheus.GaugeVec
+	PeerBytesReceived          *prometheus.GaugeVec
+	PeerBytesSentDelta         *prometheus.GaugeVec
+	PeerBytesReceivedDelta     *prometheus.GaugeVec
+	PeerReceivedBytesTotal     *prometheus.CounterVec
+	PeerSentBytesTotal         *prometheus.CounterVec
+	InterfaceBytesSent         *prometheus.GaugeVec
+	InterfaceBytesReceived     *prometheus.GaugeVec
+	InterfaceListeningPort     *prometheus.GaugeVec
+	InterfaceAllowedIPOverlaps *prometheus.GaugeVec
+	InterfaceFwMark            *prometheus.GaugeVec
+	InterfaceMTUBytes          *prometheus.GaugeVec
+	InterfaceAddressInfo       *prometheus.GaugeVec
+	InterfaceHasPublicKey      *prometheus.GaugeVec
+	InterfaceScrapeDuration    *prometheus.GaugeVec
+	InterfaceCircuitOpen       *prometheus.GaugeVec
+	InterfaceConfigAgeSeconds  *prometheus.GaugeVec
+	InterfacePeersActive       *prometheus.GaugeVec
+	InterfacePeersWithEndpoint *prometheus.GaugeVec
+	InterfacePeersNoEndpoint   *prometheus.GaugeVec
+	InterfacePeersTruncated    *prometheus.GaugeVec
+	SubnetPeers                *prometheus.GaugeVec
+	PeerEndpoint               *prometheus.GaugeVec
+	PeerEndpointChanges        *prometheus.CounterVec
+	PeerSecondsSinceTransfer   *prometheus.GaugeVec
+	PeerKeepaliveOverdue       *prometheus.GaugeVec
+	PeerPresharedKey           *prometheus.GaugeVec
+	PeerAllowedIPsCount        *prometheus.GaugeVec
+	PeerAllowedIPsV4Count      *prometheus.GaugeVec
+	PeerAllowedIPsV6Count      *prometheus.GaugeVec
+	PeerAllowedAddresses       *prometheus.GaugeVec
+	PeerAllowedIPInfo          *prometheus.GaugeVec
+	PeerInfo                   *prometheus.GaugeVec
+	PeersHandshakeAgeBucket    *prometheus.GaugeVec
+}
+
+// New creates a fresh, unregistered set of metric vectors whose names are
+// prefixed with namespace (e.g. "wireguard" gives "wireguard_peers_total").
+// The user-defined customLabels are added to every interface and peer metric, and a
+// non-empty node is set as the "node" label of every metric. helpOverrides replaces the
+// help text of the metrics it names, keyed without the namespace (e.g. "peers_total")
+func New(namespace string, customLabels []string, node string, helpOverrides map[string]string) *Metrics {
+	constLabels := nodeLabels(node)
+	help := helpText(helpOverrides)
+
+	return &Metrics{
+		ToolInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "tool_info",
+				Help:        help("tool_info", "Version of the wg tool used by the exporter (always 1)"),
+				ConstLabels: constLabels,
+			},
+			[]string{"version"},
+		),
+
+		ExporterUptimeSeconds: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "exporter",
+				Name:        "uptime_seconds",
+				Help:        help("exporter_uptime_seconds", "Seconds since the exporter started"),
+				ConstLabels: constLabels,
+			},
+		),
+		// Vectors without labels so nothing is emitted unless runtime metrics are enabled
+		ExporterGoroutines: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "exporter",
+				Name:        "goroutines",
+				Help:        help("exporter_goroutines", "Number of goroutines of the exporter process"),
+				ConstLabels: constLabels,
+			},
+			nil,
+		),
+		ExporterMemoryBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Subsystem:   "exporter",
+				Name:        "memory_bytes",
+				Help:        help("exporter_memory_bytes", "Bytes of allocated heap objects of the exporter process"),
+				ConstLabels: constLabels,
+			},
+			nil,
+		),
+
+		InterfacesDiscovered: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interfaces_discovered",
+				Help:        help("interfaces_discovered", "Number of WireGuard interfaces found, before applying the deny-list"),
+				ConstLabels: constLabels,
+			},
+		),
+
+		InterfacesFiltered: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interfaces_filtered",
+				Help:        help("interfaces_filtered", "Number of WireGuard interfaces excluded by the deny-list"),
+				ConstLabels: constLabels,
+			},
+		),
+
+		InterfaceDown: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_down",
+				Help:        help("interface_down", "Whether an expected WireGuard interface is missing (1 if down, 0 if up)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceDenied: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_denied",
+				Help:        help("interface_denied", "Always 1, one series per WireGuard interface excluded by the interfaces denylist"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		DuplicatePeerKeys: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "duplicate_peer_keys",
+				Help:        help("duplicate_peer_keys", "Number of peer public keys configured on more than one WireGuard interface"),
+				ConstLabels: constLabels,
+			},
+		),
+
+		InterfacePortConflicts: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_port_conflicts",
+				Help:        help("interface_port_conflicts", "Number of listening ports reported by more than one WireGuard interface"),
+				ConstLabels: constLabels,
+			},
+		),
+
+		PeersTotal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peers_total",
+				Help:        help("peers_total", "Number of configured peers per WireGuard interface"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		PeerLatestHandshakeSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_latest_handshake_seconds",
+				Help:        help("peer_latest_handshake_seconds", "Unix timestamp of the latest handshake per peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerHandshakeAgeSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_handshake_age_seconds",
+				Help:        help("peer_handshake_age_seconds", "Age in seconds of the latest handshake per peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		// Note: Using gauge instead of counter since WireGuard provides absolute values
+		PeerBytesSent: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_bytes_sent",
+				Help:        help("peer_bytes_sent", "Total bytes sent to peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		// Note: Using gauge instead of counter since WireGuard provides absolute values
+		PeerBytesReceived: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_bytes_received",
+				Help:        help("peer_bytes_received", "Total bytes received from peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerBytesSentDelta: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_bytes_sent_delta",
+				Help:        help("peer_bytes_sent_delta", "Bytes sent to peer since the previous scrape"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerBytesReceivedDelta: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_bytes_received_delta",
+				Help:        help("peer_bytes_received_delta", "Bytes received from peer since the previous scrape"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		// Running totals that survive interface (and, with a state file, exporter) restarts
+		PeerReceivedBytesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Name:        "peer_rx_bytes_total",
+				Help:        help("peer_rx_bytes_total", "Total bytes received from peer, carried across interface restarts"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerSentBytesTotal: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Name:        "peer_tx_bytes_total",
+				Help:        help("peer_tx_bytes_total", "Total bytes sent to peer, carried across interface restarts"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		// Note: Using gauges instead of counters since WireGuard provides absolute values
+		InterfaceBytesSent: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_bytes_sent_total",
+				Help:        help("interface_bytes_sent_total", "Total bytes sent to all peers of the WireGuard interface"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceBytesReceived: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_bytes_received_total",
+				Help:        help("interface_bytes_received_total", "Total bytes received from all peers of the WireGuard interface"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceListeningPort: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_listening_port",
+				Help:        help("interface_listening_port", "Listening port of the WireGuard interface"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceAllowedIPOverlaps: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_allowed_ip_overlaps",
+				Help:        help("interface_allowed_ip_overlaps", "Number of overlapping allowed IP pairs between different peers of the WireGuard interface"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceFwMark: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_fwmark",
+				Help:        help("interface_fwmark", "Firewall mark of the WireGuard interface (0 if off)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceMTUBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_mtu_bytes",
+				Help:        help("interface_mtu_bytes", "MTU of the WireGuard interface in bytes"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceAddressInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_address_info",
+				Help:        help("interface_address_info", "Address of the WireGuard interface from its config file (always 1, one series per address)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "address"),
+		),
+
+		InterfaceHasPublicKey: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_has_public_key",
+				Help:        help("interface_has_public_key", "Whether the WireGuard interface has a valid public key, i.e. a private key is loaded (1 if so, 0 otherwise)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceScrapeDuration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_scrape_duration_seconds",
+				Help:        help("interface_scrape_duration_seconds", "Time spent fetching the data of the interface during the scrape, including failed attempts"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceCircuitOpen: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_circuit_open",
+				Help:        help("interface_circuit_open", "Whether the WireGuard interface is skipped after failing too many scrapes in a row (1 if skipped, 0 otherwise)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfaceConfigAgeSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_config_age_seconds",
+				Help:        help("interface_config_age_seconds", "Seconds since the config file of the WireGuard interface was last modified"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfacePeersActive: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_peers_active",
+				Help:        help("interface_peers_active", "Number of peers of the WireGuard interface with a recent handshake"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfacePeersWithEndpoint: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_peers_with_endpoint",
+				Help:        help("interface_peers_with_endpoint", "Number of peers of the WireGuard interface with a known endpoint"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfacePeersNoEndpoint: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_peers_without_endpoint",
+				Help:        help("interface_peers_without_endpoint", "Number of peers of the WireGuard interface without a known endpoint"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		InterfacePeersTruncated: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "interface_peers_truncated",
+				Help:        help("interface_peers_truncated", "Number of peers of the WireGuard interface left out of the peer metrics by the max peers per interface limit"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface"),
+		),
+
+		SubnetPeers: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "subnet_peers",
+				Help:        help("subnet_peers", "Number of peers of the WireGuard interface with an allowed IP inside the tracked subnet"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "subnet"),
+		),
+
+		PeerEndpoint: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_endpoint",
+				Help:        help("peer_endpoint", "Peer endpoint information (1 if endpoint exists, 0 otherwise)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer", "endpoint", "endpoint_ip", "endpoint_port", "address_family"),
+		),
+
+		PeerEndpointChanges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   namespace,
+				Name:        "peer_endpoint_changes_total",
+				Help:        help("peer_endpoint_changes_total", "Number of times the peer endpoint changed between scrapes (roaming)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerSecondsSinceTransfer: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_seconds_since_transfer_change",
+				Help:        help("peer_seconds_since_transfer_change", "Seconds since the byte counters of the peer last changed, counted from the first scrape that saw the peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerKeepaliveOverdue: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_keepalive_overdue",
+				Help:        help("peer_keepalive_overdue", "Whether the latest handshake of a peer with persistent keepalive is older than the rekey interval plus a multiple of the keepalive interval (1 if overdue, 0 otherwise)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerPresharedKey: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_preshared_key",
+				Help:        help("peer_preshared_key", "Whether a preshared key is configured for the peer (1 if set, 0 otherwise)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerAllowedIPsCount: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_allowed_ips_count",
+				Help:        help("peer_allowed_ips_count", "Number of allowed IPs per peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerAllowedIPsV4Count: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_allowed_ips_v4_count",
+				Help:        help("peer_allowed_ips_v4_count", "Number of IPv4 allowed IPs per peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerAllowedIPsV6Count: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_allowed_ips_v6_count",
+				Help:        help("peer_allowed_ips_v6_count", "Number of IPv6 allowed IPs per peer"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerAllowedAddresses: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_allowed_addresses",
+				Help:        help("peer_allowed_addresses", "Number of addresses covered by the allowed IPs of the peer (IPv6 ranges are approximate)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer"),
+		),
+
+		PeerAllowedIPInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_allowed_ip_info",
+				Help:        help("peer_allowed_ip_info", "Allowed IP assigned to a peer (always 1, one series per CIDR)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer", "allowed_ip"),
+		),
+
+		// Info metric: always 1, descriptive labels to join against the numeric peer metrics
+		PeerInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peer_info",
+				Help:        help("peer_info", "Descriptive peer metadata (always 1)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "peer", "public_key", "display_name", "endpoint", "allowed_ips", "latest_handshake", "endpoint_type", "endpoint_hostname"),
+		),
+
+		PeersHandshakeAgeBucket: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   namespace,
+				Name:        "peers_handshake_age_bucket",
+				Help:        help("peers_handshake_age_bucket", "Number of peers per interface whose handshake age falls in the bucket (below the bound, older, or never)"),
+				ConstLabels: constLabels,
+			},
+			labelNames(customLabels, "interface", "bucket"),
+		),
+	}
+}
+
+// All returns every metric vector of the set
+func (m *Metrics) All() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.ToolInfo,
+		m.ExporterUptimeSeconds,
+		m.ExporterGoroutines,
+		m.ExporterMemoryBytes,
+		m.InterfacesDiscovered,
+		m.InterfacesFiltered,
+		m.InterfaceDown,
+		m.InterfaceDenied,
+		m.DuplicatePeerKeys,
+		m.InterfacePortConflicts,
+		m.PeersTotal,
+		m.PeerLatestHandshakeSeconds,
+		m.PeerHandshakeAgeSeconds,
+		m.PeerBytesSent,
+		m.PeerBytesReceived,
+		m.PeerBytesSentDelta,
+		m.PeerBytesReceivedDelta,
+		m.PeerReceivedBytesTotal,
+		m.PeerSentBytesTotal,
+		m.InterfaceBytesSent,
+		m.InterfaceBytesReceived,
+		m.InterfaceListeningPort,
+		m.InterfaceAllowedIPOverlaps,
+		m.InterfaceFwMark,
+		m.InterfaceMTUBytes,
+		m.InterfaceAddressInfo,
+		m.InterfaceHasPublicKey,
+		m.InterfaceScrapeDuration,
+		m.InterfaceCircuitOpen,
+		m.InterfaceConfigAgeSeconds,
+		m.InterfacePeersActive,
+		m.InterfacePeersWithEndpoint,
+		m.InterfacePeersNoEndpoint,
+		m.InterfacePeersTruncated,
+		m.SubnetPeers,
+		m.PeerEndpoint,
+		m.PeerEndpointChanges,
+		m.PeerSecondsSinceTransfer,
+		m.PeerKeepaliveOverdue,
+		m.PeerPresharedKey,
+		m.PeerAllowedIPsCount,
+		m.PeerAllowedIPsV4Count,
+		m.PeerAllowedIPsV6Count,
+		m.PeerAllowedAddresses,
+		m.PeerAllowedIPInfo,
+		m.PeerInfo,
+		m.PeersHandshakeAgeBucket,
+	}
+}
+
+// Namespace used when none is configured
+const DefaultNamespace = "wireguard"
+
+// AllMetrics is kept for backward compatibility, it returns a fresh set from New
+// using the default namespace
+func AllMetrics() []prometheus.Collector {
+	return New(DefaultNamespace, nil, "", nil).All()
+}
+
+// Help text lookup preferring the user overrides, keyed by metric name without the namespace
+func helpText(overrides map[string]string) func(name, text string) string {
+	return func(name, text string) string {
+		if help, ok := overrides[name]; ok {
+			return help
+		}
+		return text
+	}
+}
+
+// Constant labels for the node name, none if it is empty
+func nodeLabels(node string) prometheus.Labels {
+	if node == "" {
+		return nil
+	}
+	return prometheus.Labels{"node": node}
+}
+
+// Append the custom labels to the built-in label names of a metric
+func labelNames(customLabels []string, names ...string) []string {
+	return append(names, customLabels...)
+}
+
+// NewBuildInfo creates the exporter build info metric, always 1 with the build details as labels
+func NewBuildInfo(namespace, node, version, revision string) prometheus.Gauge {
+	constLabels := nodeLabels(node)
+	if constLabels == nil {
+		constLabels = prometheus.Labels{}
+	}
+	constLabels["version"] = version
+	constLabels["revision"] = revision
+	constLabels["goversion"] = runtime.Version()
+
+	buildInfo := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "exporter",
+			Name:        "build_info",
+			Help:        "Build information of the exporter (always 1)",
+			ConstLabels: constLabels,
+		},
+	)
+	buildInfo.Set(1)
+	return buildInfo
+}
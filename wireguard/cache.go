@@ -0,0 +1,136 @@
+package wireguard
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CachedCollector wraps a Collector and serves the metrics of the last scrape again when
+// a new scrape arrives sooner than MinScrapeInterval, instead of running wg again
+type CachedCollector struct {
+	collector *Collector
+
+	mu         sync.Mutex // Guards the fields below, never held while wg runs
+	lastScrape time.Time
+	snapshot   []prometheus.Metric
+	running    *cachedRun // Collection in progress, shared by the scrapes arriving meanwhile
+}
+
+// One collection shared by concurrent scrapes, snapshot is set once done is closed
+type cachedRun struct {
+	done     chan struct{}
+	snapshot []prometheus.Metric
+}
+
+func NewCachedCollector(collector *Collector) *CachedCollector {
+	return &CachedCollector{
+		collector: collector,
+	}
+}
+
+func (cc *CachedCollector) Describe(ch chan<- *prometheus.Desc) {
+	cc.collector.Describe(ch)
+}
+
+func (cc *CachedCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.collect(context.Background(), ch)
+}
+
+// WithContext returns a collector bound to ctx, see Collector.WithContext
+func (cc *CachedCollector) WithContext(ctx context.Context) prometheus.Collector {
+	return &cachedContextCollector{cached: cc, ctx: ctx}
+}
+
+type cachedContextCollector struct {
+	cached *CachedCollector
+	ctx    context.Context
+}
+
+func (ccc *cachedContextCollector) Describe(ch chan<- *prometheus.Desc) {
+	ccc.cached.Describe(ch)
+}
+
+func (ccc *cachedContextCollector) Collect(ch chan<- prometheus.Metric) {
+	ccc.cached.collect(ccc.ctx, ch)
+}
+
+// CachedSince returns when the cached metrics were collected, and whether the next scrape
+// would get them again instead of collecting
+func (cc *CachedCollector) CachedSince() (time.Time, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	interval := time.Duration(cc.collector.config().MinScrapeInterval)
+	return cc.lastScrape, interval > 0 && cc.snapshot != nil && time.Since(cc.lastScrape) < interval
+}
+
+func (cc *CachedCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	interval := time.Duration(cc.collector.config().MinScrapeInterval)
+	if interval <= 0 {
+		// Caching is off, scrapes run side by side like with a plain Collector
+		cc.collector.collect(ctx, ch)
+		return
+	}
+
+	cc.mu.Lock()
+	if cc.snapshot != nil && time.Since(cc.lastScrape) < interval {
+		snapshot, lastScrape := cc.snapshot, cc.lastScrape
+		cc.mu.Unlock()
+
+		slog.Debug("Serving cached metrics", "age", time.Since(lastScrape), "min_scrape_interval", interval)
+		// Stamped with the collection time so consumers can tell cached samples from fresh ones
+		for _, m := range snapshot {
+			ch <- prometheus.NewMetricWithTimestamp(lastScrape, m)
+		}
+		return
+	}
+
+	run := cc.running
+	if run == nil {
+		run = &cachedRun{done: make(chan struct{})}
+		cc.running = run
+		// Shared by every scrape waiting for it, so one scrape giving up doesn't cancel it for
+		// the others. The wg commands still time out on their own
+		go cc.run(context.WithoutCancel(ctx), run)
+	}
+	cc.mu.Unlock()
+
+	select {
+	case <-run.done:
+	case <-ctx.Done():
+		slog.Debug("Scrape canceled while waiting for the running collection", "error", ctx.Err())
+		return
+	}
+	for _, m := range run.snapshot {
+		ch <- m
+	}
+}
+
+// Run the real collection and keep a copy of it for the following scrapes
+func (cc *CachedCollector) run(ctx context.Context, run *cachedRun) {
+	buffer := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var snapshot []prometheus.Metric
+	go func() {
+		defer close(done)
+		for m := range buffer {
+			snapshot = append(snapshot, m)
+		}
+	}()
+	cc.collector.collect(ctx, buffer)
+	close(buffer)
+	<-done
+
+	cc.mu.Lock()
+	cc.snapshot = snapshot
+	cc.lastScrape = time.Now()
+	cc.running = nil
+	cc.mu.Unlock()
+
+	run.snapshot = snapshot
+	close(run.done)
+}
@@ -0,0 +1,142 @@
+package wireguard
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"wireguard-exporter-go/config"
+)
+
+// blockingRunner answers like fakeRunner, but the command with the block arguments waits
+// for release, announcing on started that it is running
+type blockingRunner struct {
+	fakeRunner
+	block   string
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingRunner(runner fakeRunner, block string) *blockingRunner {
+	return &blockingRunner{
+		fakeRunner: runner,
+		block:      block,
+		started:    make(chan struct{}, 16),
+		release:    make(chan struct{}),
+	}
+}
+
+func (b *blockingRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if strings.Join(args, " ") == b.block {
+		b.started <- struct{}{}
+		select {
+		case <-b.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return b.fakeRunner.Run(ctx, name, args...)
+}
+
+// Wait until a blocked command started, failing the test if none does
+func (b *blockingRunner) waitStarted(t *testing.T) {
+	t.Helper()
+	select {
+	case <-b.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked command never started")
+	}
+}
+
+// Metrics of one collection
+func collectAll(c prometheus.Collector) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+var cacheTestRunner = fakeRunner{
+	"show interfaces": "wg0\n",
+	"show wg0 dump":   "PRIV\tPUB0\t51820\toff\n" + testPeerA + "\t(none)\t(none)\t10.0.0.2/32\t0\t10\t20\toff\n",
+}
+
+// Without a minimum interval nothing is cached and scrapes don't wait for each other
+func TestCachedCollectorDisabledRunsConcurrently(t *testing.T) {
+	runner := newBlockingRunner(cacheTestRunner, "show interfaces")
+	cached := NewCachedCollector(NewCollectorWithRunner(testConfig(), runner))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			collectAll(cached)
+		}()
+	}
+
+	// Both scrapes run wg at the same time, the second one would block behind the first otherwise
+	runner.waitStarted(t)
+	runner.waitStarted(t)
+	close(runner.release)
+	wg.Wait()
+
+	if _, fresh := cached.CachedSince(); fresh {
+		t.Error("metrics cached with caching disabled")
+	}
+}
+
+func TestCachedCollectorSharesRun(t *testing.T) {
+	cfg := testConfig()
+	cfg.MinScrapeInterval = config.Duration(time.Minute)
+	runner := newBlockingRunner(cacheTestRunner, "show interfaces")
+	cached := NewCachedCollector(NewCollectorWithRunner(cfg, runner))
+
+	results := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- len(collectAll(cached))
+		}()
+	}
+	runner.waitStarted(t)
+
+	// Neither the freshness check nor a scrape that gives up waits for the running collection
+	if _, fresh := cached.CachedSince(); fresh {
+		t.Error("metrics reported as cached before the first collection finished")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if metrics := collectAll(cached.WithContext(ctx)); len(metrics) != 0 {
+		t.Errorf("canceled scrape got %d metrics", len(metrics))
+	}
+
+	close(runner.release)
+	first, second := <-results, <-results
+	if first == 0 || first != second {
+		t.Errorf("scrapes got %d and %d metrics, want the same shared run", first, second)
+	}
+	if len(runner.started) != 0 {
+		t.Errorf("%d more collections ran, want one shared run", len(runner.started))
+	}
+
+	// Served from the cache now, wg is not run again
+	if metrics := collectAll(cached); len(metrics) != first {
+		t.Errorf("cached scrape got %d metrics, want %d", len(metrics), first)
+	}
+	if _, fresh := cached.CachedSince(); !fresh {
+		t.Error("metrics not cached after the collection")
+	}
+	if len(runner.started) != 0 {
+		t.Error("cached scrape ran wg")
+	}
+}
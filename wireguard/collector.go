@@ -1,184 +1,1063 @@
-package wireguard
-
-import (
-	"fmt"
-	"log/slog"
-	"strings"
-	"time"
-	"wireguard-exporter-go/config"
-	"wireguard-exporter-go/metrics"
-
-	"github.com/prometheus/client_golang/prometheus"
-)
-
-// Implementsprometheus.Collector interface
-type Collector struct {
-	cfg *config.Config
-}
-
-// Wireguard collector
-func NewCollector(cfg *config.Config) *Collector {
-	return &Collector{
-		cfg: cfg,
-	}
-}
-
-func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-	metrics.PeersTotal.Describe(ch)
-	metrics.PeerLatestHandshakeSeconds.Describe(ch)
-	metrics.PeerHandshakeAgeSeconds.Describe(ch)
-	metrics.PeerBytesSent.Describe(ch)
-	metrics.PeerBytesReceived.Describe(ch)
-	metrics.InterfaceListeningPort.Describe(ch)
-	metrics.PeerEndpoint.Describe(ch)
-	metrics.PeerAllowedIPsCount.Describe(ch)
-}
-
-func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	// Discover interfaces
-	interfaces, err := DiscoverInterfaces(c.cfg.WGCommandPath, c.cfg.InterfacesDenylist)
-	if err != nil {
-		slog.Error("Failed to discover interfaces", "error", err)
-		// Return empty metrics instead of crashing
-		return
-	}
-
-	// Reset all metrics before collecting new data
-	// For gauges, we need to reset manually
-	metrics.PeersTotal.Reset()
-	metrics.PeerLatestHandshakeSeconds.Reset()
-	metrics.PeerHandshakeAgeSeconds.Reset()
-	metrics.PeerBytesSent.Reset()
-	metrics.PeerBytesReceived.Reset()
-	metrics.InterfaceListeningPort.Reset()
-	metrics.PeerEndpoint.Reset()
-	metrics.PeerAllowedIPsCount.Reset()
-
-	// Collect data for each interface
-	for _, ifaceName := range interfaces {
-		iface, err := ParseInterfaceData(c.cfg.WGCommandPath, ifaceName)
-		if err != nil {
-			slog.Error("Failed to parse interface data", "interface", ifaceName, "error", err)
-			continue
-		}
-
-		// Load display names from config file if enabled
-		if c.cfg.ReadConfigFiles {
-			c.loadDisplayNames(iface, ifaceName)
-		}
-
-		// Build label map for this interface
-		labels := c.buildLabels(ifaceName)
-
-		// Set interface-level metrics
-		metrics.PeersTotal.With(labels).Set(float64(len(iface.Peers)))
-		metrics.InterfaceListeningPort.With(labels).Set(float64(iface.ListeningPort))
-
-		// Set peer-level metrics
-		for _, peer := range iface.Peers {
-			peerLabels := c.buildPeerLabels(ifaceName, peer)
-
-			// Handshake metrics
-			if !peer.LatestHandshake.IsZero() {
-				metrics.PeerLatestHandshakeSeconds.With(peerLabels).Set(float64(peer.LatestHandshake.Unix()))
-				
-				// Calculate age in seconds
-				ageSeconds := time.Since(peer.LatestHandshake).Seconds()
-				metrics.PeerHandshakeAgeSeconds.With(peerLabels).Set(ageSeconds)
-			} else {
-				// Set to 0 if no handshake
-				metrics.PeerLatestHandshakeSeconds.With(peerLabels).Set(0)
-				metrics.PeerHandshakeAgeSeconds.With(peerLabels).Set(0)
-			}
-
-			// Transfer metrics (gauges - WireGuard provides absolute values)
-			metrics.PeerBytesSent.With(peerLabels).Set(float64(peer.BytesSent))
-			metrics.PeerBytesReceived.With(peerLabels).Set(float64(peer.BytesReceived))
-
-			// Endpoint metric
-			if c.cfg.ShowEndpoints && peer.Endpoint != "" {
-				endpointLabels := make(map[string]string)
-				for k, v := range peerLabels {
-					endpointLabels[k] = v
-				}
-				endpointLabels["endpoint"] = peer.Endpoint
-				metrics.PeerEndpoint.With(endpointLabels).Set(1)
-			} else {
-				// Set endpoint to empty if not showing or no endpoint
-				endpointLabels := make(map[string]string)
-				for k, v := range peerLabels {
-					endpointLabels[k] = v
-				}
-				endpointLabels["endpoint"] = ""
-				metrics.PeerEndpoint.With(endpointLabels).Set(0)
-			}
-
-			// Allowed IPs count
-			metrics.PeerAllowedIPsCount.With(peerLabels).Set(float64(len(peer.AllowedIPs)))
-		}
-	}
-
-	// Collect all metrics
-	metrics.PeersTotal.Collect(ch)
-	metrics.PeerLatestHandshakeSeconds.Collect(ch)
-	metrics.PeerHandshakeAgeSeconds.Collect(ch)
-	metrics.PeerBytesSent.Collect(ch)
-	metrics.PeerBytesReceived.Collect(ch)
-	metrics.InterfaceListeningPort.Collect(ch)
-	metrics.PeerEndpoint.Collect(ch)
-	metrics.PeerAllowedIPsCount.Collect(ch)
-}
-
-// Build a label map for interface-level metrics
-func (c *Collector) buildLabels(ifaceName string) prometheus.Labels {
-	labels := prometheus.Labels{
-		"interface": ifaceName,
-	}
-
-	return labels
-}
-
-// load display names from WireGuard config files
-func (c *Collector) loadDisplayNames(iface *Interface, ifaceName string) {
-	// Determine config file path
-	configPath := ""
-	if path, exists := c.cfg.ConfigFilePaths[ifaceName]; exists {
-		configPath = path
-	} else {
-		// Default to /etc/wireguard/<interface>.conf
-		configPath = fmt.Sprintf("/etc/wireguard/%s.conf", ifaceName)
-	}
-
-	// Parse config file to get display names
-	displayNames, err := ParseWireGuardConfigFile(configPath)
-	if err != nil {
-		slog.Debug("Failed to parse config file for display names", "interface", ifaceName, "path", configPath, "error", err)
-		return
-	}
-
-	// Update peers with display names
-	for i := range iface.Peers {
-		if displayName, exists := displayNames[iface.Peers[i].PublicKey]; exists {
-			iface.Peers[i].DisplayName = strings.ToLower(displayName)
-			slog.Debug("Loaded display name for peer", "interface", ifaceName, "public_key", iface.Peers[i].PublicKey, "display_name", displayName)
-		}
-	}
-}
-
-// Build a label map for peer-level metrics
-func (c *Collector) buildPeerLabels(ifaceName string, peer Peer) prometheus.Labels {
-	labels := prometheus.Labels{
-		"interface":       ifaceName,
-		"peer": peer.PublicKey,
-	}
-
-	// Use display name if available, otherwise fallback to public key
-	peerLabel := peer.PublicKey
-	if peer.DisplayName != "" {
-		peerLabel = peer.DisplayName
-	}
-	labels["peer"] = peerLabel
-
-	return labels
-}
-
+package wireguard
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"wireguard-exporter-go/config"
+	"wireguard-exporter-go/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WireGuard's REKEY_AFTER_TIME, a tunnel carrying traffic handshakes at least this often
+const rekeyInterval = 2 * time.Minute
+
+// Implementsprometheus.Collector interface
+type Collector struct {
+	mu      sync.RWMutex // Guards cfg, held for reading during a whole scrape
+	cfg     *config.Config
+	metrics *metrics.Metrics // Only provides the descriptors, every scrape builds its own set
+
+	// Custom label names, fixed at creation since they are part of the metric descriptors
+	customLabels []string
+
+	toolVersion string // Cached "wg --version" result, guarded by mu like cfg
+
+	counters  *byteCounters
+	endpoints *endpointTracker
+	transfers *transferTracker
+	hostnames *hostnameResolver
+	breaker   *circuitBreaker
+	startTime time.Time // For the uptime metric
+
+	runner CommandRunner // Runs the wg commands, os/exec outside of tests
+	remote bool          // wg runs on another host, local files such as sysfs say nothing about its interfaces
+}
+
+// Wireguard collector
+func NewCollector(cfg *config.Config) *Collector {
+	return NewCollectorWithRunner(cfg, ExecRunner{Env: cfg.CommandEnv})
+}
+
+// NewCollectorWithRunner creates a collector running the wg commands through runner,
+// e.g. one returning canned output
+func NewCollectorWithRunner(cfg *config.Config, runner CommandRunner) *Collector {
+	counters := newByteCounters()
+	if cfg.StateFile != "" {
+		if err := counters.load(cfg.StateFile); err != nil {
+			slog.Warn("Failed to load counter state, starting from scratch", "path", cfg.StateFile, "error", err)
+		}
+	}
+
+	c := &Collector{
+		cfg:          cfg,
+		metrics:      metrics.New(cfg.MetricNamespace, cfg.CustomLabelNames(), cfg.NodeLabel, cfg.MetricHelpOverrides),
+		customLabels: cfg.CustomLabelNames(),
+		counters:     counters,
+		endpoints:    newEndpointTracker(),
+		transfers:    newTransferTracker(),
+		hostnames:    newHostnameResolver(),
+		breaker:      newCircuitBreaker(),
+		startTime:    time.Now(),
+		runner:       runner,
+	}
+	c.toolVersion = c.wgClient().ToolVersion()
+	return c
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics.All() {
+		m.Describe(ch)
+	}
+}
+
+// Replace the configuration used by the following scrapes. Metric names and labels are
+// fixed at creation, so a changed MetricNamespace only takes effect after a restart
+func (c *Collector) SetConfig(cfg *config.Config) {
+	// Label names are part of the descriptors and cannot change without a restart
+	if !slices.Equal(cfg.CustomLabelNames(), c.customLabels) {
+		slog.Warn("Custom interface label names changed, only values of the existing names are used until restart", "names", c.customLabels)
+	}
+
+	// Only ask wg again when a different binary is configured
+	toolVersion := c.toolVersion
+	if !slices.Equal(cfg.WGCommand, c.cfg.WGCommand) {
+		toolVersion = c.wgClientFor(cfg).ToolVersion()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg = cfg
+	c.toolVersion = toolVersion
+}
+
+// Client running the configured wg command through the collector's runner, callers hold mu
+func (c *Collector) wgClient() *WGClient {
+	return c.wgClientFor(c.cfg)
+}
+
+func (c *Collector) wgClientFor(cfg *config.Config) *WGClient {
+	return &WGClient{
+		Command: cfg.WGCommand,
+		Retries: cfg.CommandRetries,
+		Runner:  c.runner,
+	}
+}
+
+// Current configuration, it can be replaced by SetConfig at any time
+func (c *Collector) config() *config.Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cfg
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(context.Background(), ch)
+}
+
+// WithContext returns a collector bound to ctx, usually the scrape request's context,
+// so wg commands are killed when the scrape is canceled or times out
+func (c *Collector) WithContext(ctx context.Context) prometheus.Collector {
+	return &contextCollector{collector: c, ctx: ctx}
+}
+
+type contextCollector struct {
+	collector *Collector
+	ctx       context.Context
+}
+
+func (cc *contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	cc.collector.Describe(ch)
+}
+
+func (cc *contextCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.collector.collect(cc.ctx, ch)
+}
+
+// Concurrent collections are safe, e.g. from several Prometheus servers: each one builds
+// its own snapshot, and the state kept across scrapes (counters, endpoints) has its own locks
+func (c *Collector) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Every message of this scrape carries the same ID, so interleaved scrapes can be told apart
+	logger := slog.With("scrape_id", newScrapeID())
+	client := c.wgClient()
+	client.Logger = logger
+
+	// Discover interfaces
+	interfaces, denied, err := client.DiscoverInterfaces(ctx, c.cfg.InterfacesDenylist, c.cfg.InterfacesDenylistPatterns)
+	if err != nil {
+		logger.Error("Failed to discover interfaces", "error", err)
+		// Return empty metrics instead of crashing
+		return
+	}
+
+	// Every scrape builds a fresh set of series and only emits it once complete, so nothing
+	// needs resetting, removed peers disappear, and concurrent scrapes don't interfere
+	snapshot := metrics.New(c.cfg.MetricNamespace, c.customLabels, c.cfg.NodeLabel, c.cfg.MetricHelpOverrides)
+
+	snapshot.ToolInfo.WithLabelValues(c.toolVersion).Set(1)
+	snapshot.ExporterUptimeSeconds.Set(time.Since(c.startTime).Seconds())
+	if c.cfg.EnableRuntimeMetrics {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		snapshot.ExporterGoroutines.WithLabelValues().Set(float64(runtime.NumGoroutine()))
+		snapshot.ExporterMemoryBytes.WithLabelValues().Set(float64(mem.HeapAlloc))
+	}
+	snapshot.InterfacesDiscovered.Set(float64(len(interfaces) + len(denied)))
+	snapshot.InterfacesFiltered.Set(float64(len(denied)))
+
+	// Only the name is known, wg is never run for denied interfaces
+	if c.cfg.ReportDeniedInterfaces {
+		for _, name := range denied {
+			snapshot.InterfaceDenied.With(c.buildLabels(name)).Set(1)
+		}
+	}
+
+	// Provisioned interfaces that were never brought up have no other metrics at all
+	for _, expected := range c.cfg.ExpectedInterfaces {
+		if slices.Contains(interfaces, expected) {
+			snapshot.InterfaceDown.With(c.buildLabels(expected)).Set(0)
+		} else {
+			logger.Warn("Expected interface is not up", "interface", expected)
+			snapshot.InterfaceDown.With(c.buildLabels(expected)).Set(1)
+		}
+	}
+
+	// Fetch interface data concurrently, results keep the discovery order
+	ifaces, durations := c.fetchInterfaces(ctx, client, interfaces)
+
+	snapshot.DuplicatePeerKeys.Set(float64(countDuplicatePeerKeys(logger, interfaces, ifaces)))
+	snapshot.InterfacePortConflicts.Set(float64(countPortConflicts(logger, interfaces, ifaces)))
+
+	// Peers with tracker state updated by this scrape, and interfaces whose state is kept as is
+	peersSeen := make(map[string]bool)
+	peersKeep := make(map[string]bool)
+
+	// Collect data for each interface
+	for i, ifaceName := range interfaces {
+		// Also for failed interfaces, a timeout is usually why the scrape is slow
+		snapshot.InterfaceScrapeDuration.With(c.buildLabels(ifaceName)).Set(durations[i].Seconds())
+
+		if c.cfg.CircuitBreakerThreshold > 0 {
+			circuitOpen := 0.0
+			if c.breaker.open(ifaceName, time.Now()) {
+				circuitOpen = 1
+			}
+			snapshot.InterfaceCircuitOpen.With(c.buildLabels(ifaceName)).Set(circuitOpen)
+		}
+
+		iface := ifaces[i]
+		if iface == nil {
+			// Failed to fetch, already logged
+			peersKeep[ifaceName] = true
+			continue
+		}
+
+		// Denylisted peers normally still count in the interface totals
+		if c.cfg.PeersDenylistExcludeTotals {
+			iface.Peers = slices.DeleteFunc(iface.Peers, func(peer Peer) bool {
+				return c.peerDenied(peer.PublicKey)
+			})
+		}
+
+		// Build label map for this interface
+		labels := c.buildLabels(ifaceName)
+
+		// Set interface-level metrics
+		snapshot.PeersTotal.With(labels).Set(float64(len(iface.Peers)))
+		if iface.ListeningPort != unknownListeningPort {
+			snapshot.InterfaceListeningPort.With(labels).Set(float64(iface.ListeningPort))
+		}
+		snapshot.InterfaceFwMark.With(labels).Set(float64(iface.FwMark))
+		if iface.MTU > 0 {
+			snapshot.InterfaceMTUBytes.With(labels).Set(float64(iface.MTU))
+		}
+
+		for _, address := range iface.Addresses {
+			addressLabels := make(map[string]string)
+			for k, v := range labels {
+				addressLabels[k] = v
+			}
+			addressLabels["address"] = address
+			c.redactLabels(addressLabels, "address")
+			snapshot.InterfaceAddressInfo.With(addressLabels).Set(1)
+		}
+
+		if validKey(iface.PublicKey) {
+			snapshot.InterfaceHasPublicKey.With(labels).Set(1)
+		} else {
+			logger.Warn("Interface has no valid public key, no private key is loaded", "interface", ifaceName)
+			snapshot.InterfaceHasPublicKey.With(labels).Set(0)
+		}
+
+		if !iface.ConfigModTime.IsZero() {
+			snapshot.InterfaceConfigAgeSeconds.With(labels).Set(time.Since(iface.ConfigModTime).Seconds())
+		}
+
+		// Relatively expensive, see countAllowedIPOverlaps
+		if c.cfg.DetectAllowedIPOverlaps {
+			snapshot.InterfaceAllowedIPOverlaps.With(labels).Set(float64(countAllowedIPOverlaps(logger, ifaceName, iface.Peers)))
+		}
+
+		// Handshake ages of this interface's peers, used for the bucket counts
+		var handshakeAges []time.Duration
+		neverHandshaked := 0
+
+		// Interface totals, summed over peers
+		var bytesSent, bytesReceived uint64
+		var peersActive, peersWithEndpoint int
+
+		// Handshake age per peer, indexed like iface.Peers
+		peerAges := make([]time.Duration, len(iface.Peers))
+
+		// Interface-level aggregates include every peer, also the stale ones skipped below
+		for i, peer := range iface.Peers {
+			bytesSent += peer.BytesSent
+			bytesReceived += peer.BytesReceived
+
+			if peer.Endpoint != "" {
+				peersWithEndpoint++
+			}
+
+			if peer.LatestHandshake.IsZero() {
+				neverHandshaked++
+				continue
+			}
+
+			age := time.Since(peer.LatestHandshake)
+			if age < 0 {
+				// Handshake in the future, the wall clock jumped (NTP correction, VM resume)
+				logger.Debug("Negative handshake age, clamping to 0", "interface", ifaceName, "public_key", peer.PublicKey, "age", age)
+				age = 0
+			}
+			peerAges[i] = age
+			handshakeAges = append(handshakeAges, age)
+
+			if age <= time.Duration(c.cfg.PeerUpThreshold) {
+				peersActive++
+			}
+		}
+
+		// Aggregate-only mode keeps just the interface-level series, for hubs with many peers
+		peers := iface.Peers
+		if c.cfg.AggregateOnly {
+			peers = nil
+		}
+
+		// Cardinality cap, the totals above still count every peer
+		var truncated []bool
+		if c.cfg.MaxPeersPerInterface > 0 {
+			var dropped int
+			truncated, dropped = truncatePeers(peers, c.cfg.MaxPeersPerInterface)
+			if dropped > 0 {
+				logger.Warn("Too many peers, leaving out the least recently handshaked ones", "interface", ifaceName, "peers", len(peers), "max_peers_per_interface", c.cfg.MaxPeersPerInterface, "truncated", dropped)
+			}
+			snapshot.InterfacePeersTruncated.With(labels).Set(float64(dropped))
+		}
+
+		// Set peer-level metrics
+		for i, peer := range peers {
+			if c.peerDenied(peer.PublicKey) {
+				continue
+			}
+
+			if truncated != nil && truncated[i] {
+				continue
+			}
+
+			// Skip long-dead peers and peers that never connected
+			if c.cfg.MaxPeerStaleness > 0 && (peer.LatestHandshake.IsZero() || peerAges[i] > time.Duration(c.cfg.MaxPeerStaleness)) {
+				continue
+			}
+
+			peerLabels := c.buildPeerLabels(ifaceName, peer)
+
+			// Handshake metrics
+			if !peer.LatestHandshake.IsZero() {
+				snapshot.PeerLatestHandshakeSeconds.With(peerLabels).Set(float64(peer.LatestHandshake.Unix()))
+				snapshot.PeerHandshakeAgeSeconds.With(peerLabels).Set(peerAges[i].Seconds())
+			} else {
+				// Set to 0 if no handshake
+				snapshot.PeerLatestHandshakeSeconds.With(peerLabels).Set(0)
+				snapshot.PeerHandshakeAgeSeconds.With(peerLabels).Set(0)
+			}
+
+			// Keepalives keep a tunnel busy, so its handshake renews every rekey interval. Much
+			// older means a dead tunnel that should have recovered on its own
+			if peer.PersistentKeepalive > 0 && !peer.LatestHandshake.IsZero() {
+				keepalive := time.Duration(peer.PersistentKeepalive) * time.Second
+				overdue := 0.0
+				if peerAges[i] > rekeyInterval+time.Duration(c.cfg.KeepaliveOverdueFactor*float64(keepalive)) {
+					overdue = 1
+				}
+				snapshot.PeerKeepaliveOverdue.With(peerLabels).Set(overdue)
+			}
+
+			// Transfer metrics (gauges - WireGuard provides absolute values)
+			snapshot.PeerBytesSent.With(peerLabels).Set(float64(peer.BytesSent))
+			snapshot.PeerBytesReceived.With(peerLabels).Set(float64(peer.BytesReceived))
+
+			peersSeen[ifaceName+"/"+peer.PublicKey] = true
+
+			// Running totals, carried across interface restarts
+			counters := c.counters.update(ifaceName, peer.PublicKey, peer.BytesReceived, peer.BytesSent)
+			if c.cfg.EnableExemplars {
+				exemplar := c.peerExemplar(peer)
+				snapshot.PeerReceivedBytesTotal.With(peerLabels).(prometheus.ExemplarAdder).AddWithExemplar(float64(counters.TotalReceived), exemplar)
+				snapshot.PeerSentBytesTotal.With(peerLabels).(prometheus.ExemplarAdder).AddWithExemplar(float64(counters.TotalSent), exemplar)
+			} else {
+				snapshot.PeerReceivedBytesTotal.With(peerLabels).Add(float64(counters.TotalReceived))
+				snapshot.PeerSentBytesTotal.With(peerLabels).Add(float64(counters.TotalSent))
+			}
+			snapshot.PeerBytesReceivedDelta.With(peerLabels).Set(float64(counters.DeltaReceived))
+			snapshot.PeerBytesSentDelta.With(peerLabels).Set(float64(counters.DeltaSent))
+
+			// Roaming detection, the first observation counts as 0 changes
+			endpointChanges := c.endpoints.update(ifaceName, peer.PublicKey, peer.Endpoint)
+			snapshot.PeerEndpointChanges.With(peerLabels).Add(float64(endpointChanges))
+
+			// Tunnel up without traffic, the counters stop moving while handshakes go on
+			sinceTransfer := c.transfers.update(ifaceName, peer.PublicKey, peer.BytesReceived, peer.BytesSent, time.Now())
+			snapshot.PeerSecondsSinceTransfer.With(peerLabels).Set(sinceTransfer.Seconds())
+
+			// Endpoint metric
+			if c.cfg.ShowEndpoints && peer.Endpoint != "" {
+				endpointLabels := make(map[string]string)
+				for k, v := range peerLabels {
+					endpointLabels[k] = v
+				}
+				endpointLabels["endpoint"] = peer.Endpoint
+				endpointLabels["endpoint_ip"] = peer.EndpointIP
+				endpointLabels["address_family"] = peer.EndpointFamily
+				endpointLabels["endpoint_port"] = ""
+				if peer.EndpointPort != 0 {
+					endpointLabels["endpoint_port"] = strconv.Itoa(peer.EndpointPort)
+				}
+				c.redactLabels(endpointLabels, "endpoint", "endpoint_ip", "endpoint_port", "address_family")
+				snapshot.PeerEndpoint.With(endpointLabels).Set(1)
+			} else {
+				// Set endpoint to empty if not showing or no endpoint
+				endpointLabels := make(map[string]string)
+				for k, v := range peerLabels {
+					endpointLabels[k] = v
+				}
+				endpointLabels["endpoint"] = ""
+				endpointLabels["endpoint_ip"] = ""
+				endpointLabels["address_family"] = ""
+				endpointLabels["endpoint_port"] = ""
+				snapshot.PeerEndpoint.With(endpointLabels).Set(0)
+			}
+
+			if peer.HasPresharedKey {
+				snapshot.PeerPresharedKey.With(peerLabels).Set(1)
+			} else {
+				snapshot.PeerPresharedKey.With(peerLabels).Set(0)
+			}
+
+			// Allowed IPs count
+			snapshot.PeerAllowedIPsCount.With(peerLabels).Set(float64(len(peer.AllowedIPs)))
+			v4, v6 := countAllowedIPFamilies(logger, ifaceName, peer.AllowedIPs)
+			snapshot.PeerAllowedIPsV4Count.With(peerLabels).Set(float64(v4))
+			snapshot.PeerAllowedIPsV6Count.With(peerLabels).Set(float64(v6))
+			snapshot.PeerAllowedAddresses.With(peerLabels).Set(countAllowedAddresses(logger, ifaceName, peer.AllowedIPs))
+
+			c.setPeerInfo(snapshot, peerLabels, peer)
+
+			// Allowed IP info, one series per CIDR
+			if c.cfg.ShowAllowedIPs {
+				for _, allowedIP := range peer.AllowedIPs {
+					allowedIPLabels := make(map[string]string)
+					for k, v := range peerLabels {
+						allowedIPLabels[k] = v
+					}
+					allowedIPLabels["allowed_ip"] = allowedIP
+					c.redactLabels(allowedIPLabels, "allowed_ip")
+					snapshot.PeerAllowedIPInfo.With(allowedIPLabels).Set(1)
+				}
+			}
+		}
+
+		snapshot.InterfaceBytesSent.With(labels).Set(float64(bytesSent))
+		snapshot.InterfaceBytesReceived.With(labels).Set(float64(bytesReceived))
+		snapshot.InterfacePeersActive.With(labels).Set(float64(peersActive))
+		snapshot.InterfacePeersWithEndpoint.With(labels).Set(float64(peersWithEndpoint))
+		snapshot.InterfacePeersNoEndpoint.With(labels).Set(float64(len(iface.Peers) - peersWithEndpoint))
+		c.setHandshakeAgeBuckets(snapshot, labels, handshakeAges, neverHandshaked)
+		c.setSubnetPeers(logger, snapshot, labels, ifaceName, iface.Peers)
+	}
+
+	// Gone peers would otherwise pile up, in memory and in the state file
+	c.counters.prune(peersSeen, peersKeep)
+	c.endpoints.prune(peersSeen, peersKeep)
+	c.transfers.prune(peersSeen, peersKeep)
+	c.breaker.prune(interfaces)
+
+	if c.cfg.StateFile != "" {
+		if err := c.counters.save(c.cfg.StateFile); err != nil {
+			logger.Error("Failed to save counter state", "path", c.cfg.StateFile, "error", err)
+		}
+	}
+
+	// Collect all metrics
+	for _, m := range snapshot.All() {
+		m.Collect(ch)
+	}
+}
+
+// Ready reports whether wg can list the interfaces, for readiness probes. It gives up
+// after a short timeout so a hanging wg doesn't block the probe
+func (c *Collector) Ready(ctx context.Context) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	_, _, err := c.wgClient().DiscoverInterfaces(ctx, c.cfg.InterfacesDenylist, c.cfg.InterfacesDenylistPatterns)
+	return err
+}
+
+// Interfaces returns the parsed interfaces with their peers, as the next scrape would see
+// them. Public keys are shortened when RedactPublicKeys is set
+func (c *Collector) Interfaces(ctx context.Context) ([]Interface, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	client := c.wgClient()
+	names, _, err := client.DiscoverInterfaces(ctx, c.cfg.InterfacesDenylist, c.cfg.InterfacesDenylistPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := []Interface{}
+	ifaces, _ := c.fetchInterfaces(ctx, client, names)
+	for _, iface := range ifaces {
+		if iface == nil {
+			// Failed to fetch, already logged
+			continue
+		}
+
+		if c.cfg.RedactPublicKeys {
+			iface.PublicKey = shortKey(iface.PublicKey)
+			for i := range iface.Peers {
+				iface.Peers[i].PublicKey = shortKey(iface.Peers[i].PublicKey)
+			}
+		}
+		interfaces = append(interfaces, *iface)
+	}
+	return interfaces, nil
+}
+
+// Set the peer info metric. Endpoint is only filled when endpoints are shown
+func (c *Collector) setPeerInfo(snapshot *metrics.Metrics, peerLabels prometheus.Labels, peer Peer) {
+	infoLabels := make(map[string]string)
+	for k, v := range peerLabels {
+		infoLabels[k] = v
+	}
+	infoLabels["public_key"] = peer.PublicKey
+	infoLabels["display_name"] = peer.DisplayName
+	infoLabels["endpoint"] = ""
+	infoLabels["endpoint_hostname"] = ""
+	if c.cfg.ShowEndpoints {
+		infoLabels["endpoint"] = peer.Endpoint
+		if c.cfg.ResolveEndpointHostnames && peer.EndpointIP != "" {
+			infoLabels["endpoint_hostname"] = c.hostnames.hostname(peer.EndpointIP)
+		}
+	}
+
+	allowedIPs := strings.Join(peer.AllowedIPs, ",")
+	if limit := c.cfg.PeerInfoAllowedIPsMaxLength; limit > 0 && len(allowedIPs) > limit {
+		allowedIPs = allowedIPs[:limit] + "..."
+	}
+	infoLabels["allowed_ips"] = allowedIPs
+
+	infoLabels["endpoint_type"] = peer.EndpointType
+	if peer.EndpointType == "" {
+		infoLabels["endpoint_type"] = "unknown"
+	}
+
+	infoLabels["latest_handshake"] = ""
+	if !peer.LatestHandshake.IsZero() {
+		infoLabels["latest_handshake"] = peer.LatestHandshake.UTC().Format(time.RFC3339)
+	}
+
+	c.redactLabels(infoLabels, "public_key", "display_name", "endpoint", "allowed_ips", "latest_handshake", "endpoint_type", "endpoint_hostname")
+
+	snapshot.PeerInfo.With(infoLabels).Set(1)
+}
+
+// Fetch the data of all interfaces with at most MaxConcurrency wg commands running at once.
+// The results have one entry per interface name, nil for interfaces that failed or whose
+// circuit breaker is open, along with the time each fetch took
+func (c *Collector) fetchInterfaces(ctx context.Context, client *WGClient, interfaces []string) ([]*Interface, []time.Duration) {
+	results := make([]*Interface, len(interfaces))
+	durations := make([]time.Duration, len(interfaces))
+
+	workers := c.cfg.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+
+	threshold := c.cfg.CircuitBreakerThreshold
+	cooldown := time.Duration(c.cfg.CircuitBreakerCooldown)
+
+	var wg sync.WaitGroup
+	for i, ifaceName := range interfaces {
+		if threshold > 0 && c.breaker.open(ifaceName, time.Now()) {
+			client.log().Debug("Skipping interface, it failed too often", "interface", ifaceName)
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, ifaceName string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// Each goroutine writes only its own slots
+			start := time.Now()
+			iface, err := c.fetchInterface(ctx, client, ifaceName)
+			durations[i] = time.Since(start)
+			if threshold > 0 && c.breaker.record(ifaceName, err == nil, threshold, cooldown, time.Now()) {
+				client.log().Warn("Interface failed too many times in a row, skipping it", "interface", ifaceName, "threshold", threshold, "cooldown", cooldown)
+			}
+			if err != nil {
+				client.log().Error("Failed to parse interface data", "interface", ifaceName, "error", err)
+				return
+			}
+			results[i] = iface
+		}(i, ifaceName)
+	}
+	wg.Wait()
+
+	return results, durations
+}
+
+// Run wg for one interface and load its display names
+func (c *Collector) fetchInterface(ctx context.Context, client *WGClient, ifaceName string) (*Interface, error) {
+	var iface *Interface
+	var err error
+	if c.cfg.OutputFormat == "human" {
+		iface, err = client.ParseInterfaceDataHuman(ctx, ifaceName)
+	} else {
+		iface, err = client.ParseInterfaceData(ctx, ifaceName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A dump file may come from another host, a local interface of the same name says nothing about it
+	if c.cfg.DumpFile == "" && !c.remote {
+		if mtu, err := readInterfaceMTU(ifaceName); err == nil {
+			iface.MTU = mtu
+		} else {
+			client.log().Debug("Failed to read interface MTU", "interface", ifaceName, "error", err)
+		}
+	}
+
+	// Enrich peers from wg showconf if enabled, for setups where the config files are not readable
+	if c.cfg.ReadShowconf {
+		c.loadShowconf(ctx, client, iface, ifaceName)
+	}
+
+	// Load display names from config file if enabled, these take precedence
+	if c.cfg.ReadConfigFiles {
+		c.loadDisplayNames(client.log(), iface, ifaceName)
+	}
+
+	return iface, nil
+}
+
+// Update peers with the display names and preshared key presence from wg showconf
+func (c *Collector) loadShowconf(ctx context.Context, client *WGClient, iface *Interface, ifaceName string) {
+	showconfPeers, err := client.ParseShowconf(ctx, ifaceName)
+	if err != nil {
+		client.log().Debug("Failed to read wg showconf", "interface", ifaceName, "error", err)
+		return
+	}
+
+	for i := range iface.Peers {
+		showconfPeer, exists := showconfPeers[iface.Peers[i].PublicKey]
+		if !exists {
+			continue
+		}
+		if showconfPeer.HasPresharedKey {
+			iface.Peers[i].HasPresharedKey = true
+		}
+		if showconfPeer.DisplayName != "" {
+			iface.Peers[i].DisplayName = strings.ToLower(showconfPeer.DisplayName)
+		}
+	}
+}
+
+// Count peers per handshake age bucket. Buckets are exclusive: each peer lands in the
+// first bucket whose bound is above its age, in "older" if none is, or in "never"
+func (c *Collector) setHandshakeAgeBuckets(snapshot *metrics.Metrics, labels prometheus.Labels, ages []time.Duration, never int) {
+	counts := make([]int, len(c.cfg.HandshakeAgeBuckets))
+	older := 0
+
+	for _, age := range ages {
+		placed := false
+		for i, bound := range c.cfg.HandshakeAgeBuckets {
+			if age < time.Duration(bound) {
+				counts[i]++
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			older++
+		}
+	}
+
+	setBucket := func(bucket string, count int) {
+		bucketLabels := make(map[string]string)
+		for k, v := range labels {
+			bucketLabels[k] = v
+		}
+		bucketLabels["bucket"] = bucket
+		c.redactLabels(bucketLabels, "bucket")
+		snapshot.PeersHandshakeAgeBucket.With(bucketLabels).Set(float64(count))
+	}
+
+	for i, bound := range c.cfg.HandshakeAgeBuckets {
+		setBucket(time.Duration(bound).String(), counts[i])
+	}
+	setBucket("older", older)
+	setBucket("never", never)
+}
+
+// Count the peers under each tracked subnet, a peer counts once per subnet when any of its
+// allowed IPs lies inside it. Tracked subnets without peers are reported as 0
+func (c *Collector) setSubnetPeers(logger *slog.Logger, snapshot *metrics.Metrics, labels prometheus.Labels, ifaceName string, peers []Peer) {
+	if len(c.cfg.TrackedSubnetNets) == 0 {
+		return
+	}
+
+	counts := make([]int, len(c.cfg.TrackedSubnetNets))
+	for _, peer := range peers {
+		for i, subnet := range c.cfg.TrackedSubnetNets {
+			if peerInSubnet(logger, ifaceName, peer, subnet) {
+				counts[i]++
+			}
+		}
+	}
+
+	for i, subnet := range c.cfg.TrackedSubnetNets {
+		subnetLabels := make(map[string]string)
+		for k, v := range labels {
+			subnetLabels[k] = v
+		}
+		subnetLabels["subnet"] = subnet.String()
+		c.redactLabels(subnetLabels, "subnet")
+		snapshot.SubnetPeers.With(subnetLabels).Set(float64(counts[i]))
+	}
+}
+
+// Whether one of the allowed IPs of the peer lies inside subnet
+func peerInSubnet(logger *slog.Logger, ifaceName string, peer Peer, subnet *net.IPNet) bool {
+	subnetOnes, subnetBits := subnet.Mask.Size()
+	for _, allowedIP := range peer.AllowedIPs {
+		_, ipNet, err := net.ParseCIDR(allowedIP)
+		if err != nil {
+			logger.Debug("Skipping malformed allowed IP", "interface", ifaceName, "allowed_ip", allowedIP, "error", err)
+			continue
+		}
+		ones, bits := ipNet.Mask.Size()
+		if bits == subnetBits && ones >= subnetOnes && subnet.Contains(ipNet.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Build a label map for interface-level metrics
+func (c *Collector) buildLabels(ifaceName string) prometheus.Labels {
+	labels := prometheus.Labels{
+		"interface": c.interfaceLabel(ifaceName),
+	}
+	c.redactLabels(labels, "interface")
+	c.addCustomLabels(labels, ifaceName)
+
+	return labels
+}
+
+// Add the custom labels of the interface, the same ones on interface and peer metrics.
+// Every custom label name gets a value, empty when this interface doesn't define it
+func (c *Collector) addCustomLabels(labels prometheus.Labels, ifaceName string) {
+	values := c.cfg.InterfaceLabelsFor(ifaceName)
+	for _, name := range c.customLabels {
+		labels[name] = values[name]
+	}
+	c.redactLabels(labels, c.customLabels...)
+}
+
+// Value of the interface label, the configured alias or the raw interface name
+func (c *Collector) interfaceLabel(ifaceName string) string {
+	if alias, exists := c.cfg.InterfaceAliases[ifaceName]; exists && alias != "" {
+		return alias
+	}
+	return ifaceName
+}
+
+// load display names and endpoint types from WireGuard config files
+func (c *Collector) loadDisplayNames(logger *slog.Logger, iface *Interface, ifaceName string) {
+	// Determine config file path
+	configPath := ""
+	if path, exists := c.cfg.ConfigFilePaths[ifaceName]; exists {
+		configPath = path
+	} else {
+		// Default to <config dir>/<interface>.conf
+		configPath = filepath.Join(c.cfg.ConfigDir, ifaceName+".conf")
+	}
+
+	// Parse config file to get display names, endpoint types and the interface addresses
+	configFile, err := ParseWireGuardConfig(logger, configPath)
+	if err != nil {
+		logger.Debug("Failed to parse config file for display names", "interface", ifaceName, "path", configPath, "error", err)
+		return
+	}
+	configPeers := configFile.Peers
+	iface.Addresses = configFile.Addresses
+
+	// Rough proxy for how long keys have gone without rotation
+	if info, err := os.Stat(configPath); err == nil {
+		iface.ConfigModTime = info.ModTime()
+	}
+
+	// Update peers with display names
+	for i := range iface.Peers {
+		configPeer, exists := configPeers[iface.Peers[i].PublicKey]
+		if !exists {
+			continue
+		}
+
+		// Peers with an Endpoint are dialed by this side, the others roam and dial in
+		iface.Peers[i].EndpointType = "roaming"
+		if configPeer.HasEndpoint {
+			iface.Peers[i].EndpointType = "static"
+		}
+
+		if displayName := configPeer.DisplayName; displayName != "" {
+			iface.Peers[i].DisplayName = strings.ToLower(displayName)
+			logger.Debug("Loaded display name for peer", "interface", ifaceName, "public_key", iface.Peers[i].PublicKey, "display_name", displayName)
+		}
+	}
+}
+
+// Whether the public key is in PeersDenylist, in full or by one of its prefixes
+func (c *Collector) peerDenied(publicKey string) bool {
+	for _, entry := range c.cfg.PeersDenylist {
+		if entry != "" && strings.HasPrefix(publicKey, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// Build a label map for peer-level metrics
+func (c *Collector) buildPeerLabels(ifaceName string, peer Peer) prometheus.Labels {
+	// Use display name if available, otherwise fallback to public key
+	peerLabel := c.peerKeyLabel(peer.PublicKey)
+	if peer.DisplayName != "" {
+		peerLabel = peer.DisplayName
+	}
+
+	// Label names must match the peer metrics declared in metrics.New
+	labels := prometheus.Labels{
+		"interface": c.interfaceLabel(ifaceName),
+		"peer":      peerLabel,
+	}
+	c.redactLabels(labels, "interface", "peer")
+	c.addCustomLabels(labels, ifaceName)
+
+	return labels
+}
+
+
+// Transform a public key for use as a label value according to PeerKeyLabelMode
+func (c *Collector) peerKeyLabel(publicKey string) string {
+	switch c.cfg.PeerKeyLabelMode {
+	case "short":
+		return shortKey(publicKey)
+	case "hash":
+		h := fnv.New32a()
+		h.Write([]byte(publicKey))
+		return fmt.Sprintf("%08x", h.Sum32())
+	default:
+		return publicKey
+	}
+}
+
+// Labels that tell series of the same metric apart. Dropping their values could merge
+// series, so denylisted ones are hashed instead of emptied
+var identityLabels = map[string]bool{
+	"interface": true, "peer": true, "allowed_ip": true, "bucket": true, "address": true, "subnet": true,
+}
+
+// Exemplar labels linking a peer's traffic to logs, the short key and the endpoint when it is
+// shown. Labels in LabelDenylist are left out, exemplars are not redacted like series labels
+func (c *Collector) peerExemplar(peer Peer) prometheus.Labels {
+	exemplar := prometheus.Labels{}
+	if !slices.Contains(c.cfg.LabelDenylist, "peer") && !slices.Contains(c.cfg.LabelDenylist, "public_key") {
+		exemplar["peer"] = shortKey(peer.PublicKey)
+	}
+	if c.cfg.ShowEndpoints && peer.Endpoint != "" && !slices.Contains(c.cfg.LabelDenylist, "endpoint") {
+		exemplar["endpoint"] = peer.Endpoint
+	}
+	return exemplar
+}
+
+// Hide the values of the labels among names that are in LabelDenylist. Applied where the
+// labels are set, so values already hashed are never hashed again
+func (c *Collector) redactLabels(labels prometheus.Labels, names ...string) {
+	for _, name := range names {
+		if !slices.Contains(c.cfg.LabelDenylist, name) {
+			continue
+		}
+		if identityLabels[name] {
+			// 64 bits keeps collisions unlikely even with thousands of peers
+			h := fnv.New64a()
+			h.Write([]byte(labels[name]))
+			labels[name] = fmt.Sprintf("%016x", h.Sum64())
+		} else {
+			labels[name] = ""
+		}
+	}
+}
+
+// Whether key is a base64 encoded 32 byte WireGuard key
+func validKey(key string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	return err == nil && len(decoded) == 32
+}
+
+// First 8 characters of a public key, enough to tell peers apart
+func shortKey(publicKey string) string {
+	if len(publicKey) > 8 {
+		return publicKey[:8] + "..."
+	}
+	return publicKey
+}
+
+// Short random ID to correlate the log messages of one scrape
+func newScrapeID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// Number of addresses covered by the allowed IPs, 2^(bits-prefix) per CIDR. Float64 keeps
+// the magnitude of large IPv6 ranges but not every digit. Overlapping CIDRs are counted twice
+func countAllowedAddresses(logger *slog.Logger, ifaceName string, allowedIPs []string) float64 {
+	total := 0.0
+	for _, allowedIP := range allowedIPs {
+		_, ipNet, err := net.ParseCIDR(allowedIP)
+		if err != nil {
+			logger.Debug("Skipping malformed allowed IP", "interface", ifaceName, "allowed_ip", allowedIP, "error", err)
+			continue
+		}
+		ones, bits := ipNet.Mask.Size()
+		total += math.Ldexp(1, bits-ones)
+	}
+	return total
+}
+
+// Number of IPv4 and IPv6 CIDRs among the allowed IPs, malformed ones count as neither
+func countAllowedIPFamilies(logger *slog.Logger, ifaceName string, allowedIPs []string) (v4, v6 int) {
+	for _, allowedIP := range allowedIPs {
+		_, ipNet, err := net.ParseCIDR(allowedIP)
+		if err != nil {
+			logger.Debug("Skipping malformed allowed IP", "interface", ifaceName, "allowed_ip", allowedIP, "error", err)
+			continue
+		}
+		if ipNet.IP.To4() != nil {
+			v4++
+		} else {
+			v6++
+		}
+	}
+	return v4, v6
+}
+
+// Count public keys that are peers on more than one interface, usually a config copied
+// between interfaces. The fetched ifaces are indexed like names, nil if fetching failed
+func countDuplicatePeerKeys(logger *slog.Logger, names []string, ifaces []*Interface) int {
+	seen := make(map[string][]string)
+	for i, iface := range ifaces {
+		if iface == nil {
+			continue
+		}
+		for _, peer := range iface.Peers {
+			seen[peer.PublicKey] = append(seen[peer.PublicKey], names[i])
+		}
+	}
+
+	duplicates := 0
+	for publicKey, ifaceNames := range seen {
+		if len(ifaceNames) > 1 {
+			logger.Warn("Peer public key found on more than one interface", "public_key", shortKey(publicKey), "interfaces", ifaceNames)
+			duplicates++
+		}
+	}
+	return duplicates
+}
+
+// Count listening ports reported by more than one interface, a misconfiguration. The fetched
+// ifaces are indexed like names, nil if fetching failed
+func countPortConflicts(logger *slog.Logger, names []string, ifaces []*Interface) int {
+	ports := make(map[int][]string)
+	for i, iface := range ifaces {
+		if iface == nil || iface.ListeningPort <= 0 {
+			continue
+		}
+		ports[iface.ListeningPort] = append(ports[iface.ListeningPort], names[i])
+	}
+
+	conflicts := 0
+	for port, ifaceNames := range ports {
+		if len(ifaceNames) > 1 {
+			logger.Warn("Listening port reported by more than one interface", "port", port, "interfaces", ifaceNames)
+			conflicts++
+		}
+	}
+	return conflicts
+}
+
+// Mark the peers beyond the first limit, ordered by most recent handshake with never
+// handshaked peers last, and count them. The marks are indexed like peers
+func truncatePeers(peers []Peer, limit int) ([]bool, int) {
+	if len(peers) <= limit {
+		return nil, 0
+	}
+
+	order := make([]int, len(peers))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return peers[b].LatestHandshake.Compare(peers[a].LatestHandshake)
+	})
+
+	truncated := make([]bool, len(peers))
+	for _, i := range order[limit:] {
+		truncated[i] = true
+	}
+	return truncated, len(peers) - limit
+}
+
+// Count pairs of allowed IPs from different peers that overlap, which usually means a
+// misconfiguration. Every CIDR is compared with every CIDR of the other peers, so the cost
+// is O(n^2) in the total number of allowed IPs of the interface
+func countAllowedIPOverlaps(logger *slog.Logger, ifaceName string, peers []Peer) int {
+	type peerNet struct {
+		peer int
+		net  *net.IPNet
+	}
+
+	var nets []peerNet
+	for i, peer := range peers {
+		for _, allowedIP := range peer.AllowedIPs {
+			_, ipNet, err := net.ParseCIDR(allowedIP)
+			if err != nil {
+				logger.Debug("Skipping invalid allowed IP in overlap detection", "interface", ifaceName, "allowed_ip", allowedIP, "error", err)
+				continue
+			}
+			nets = append(nets, peerNet{peer: i, net: ipNet})
+		}
+	}
+
+	overlaps := 0
+	for i := 0; i < len(nets); i++ {
+		for j := i + 1; j < len(nets); j++ {
+			if nets[i].peer == nets[j].peer {
+				continue
+			}
+			// Two CIDRs overlap exactly when one contains the network address of the other
+			if nets[i].net.Contains(nets[j].net.IP) || nets[j].net.Contains(nets[i].net.IP) {
+				overlaps++
+			}
+		}
+	}
+	return overlaps
+}
@@ -0,0 +1,296 @@
+package wireguard
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"wireguard-exporter-go/config"
+)
+
+// Configuration running the fake wg command, without any local files
+func testConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.WGCommand = []string{"wg"}
+	cfg.ReadConfigFiles = false
+	return cfg
+}
+
+// Gather the collector through a registry, which checks the series against their descriptors
+func gather(t *testing.T, c prometheus.Collector) map[string]*dto.MetricFamily {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather failed: %v", err)
+	}
+
+	byName := make(map[string]*dto.MetricFamily)
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	return byName
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, pair := range m.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	return labels
+}
+
+// Series of a metric family whose labels include all of the given ones
+func findMetric(family *dto.MetricFamily, labels map[string]string) *dto.Metric {
+	if family == nil {
+		return nil
+	}
+	for _, m := range family.GetMetric() {
+		have := labelMap(m)
+		matches := true
+		for name, value := range labels {
+			if have[name] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestCollectorCustomLabels(t *testing.T) {
+	cfg := testConfig()
+	cfg.NodeLabel = "node1"
+	cfg.InterfaceLabels = map[string]map[string]string{
+		"wg0": {"site": "fra", "role": "hub"},
+		"wg1": {"site": "ams"},
+	}
+
+	runner := fakeRunner{
+		"--version":       "wireguard-tools v1.0.20210914 - https://git.zx2c4.com/wireguard-tools/\n",
+		"show interfaces": "wg0 wg1\n",
+		"show wg0 dump":   "PRIV\tPUB0\t51820\toff\n" + testPeerA + "\t(none)\t1.2.3.4:5555\t10.0.0.2/32\t0\t10\t20\toff\n",
+		"show wg1 dump":   "PRIV\tPUB1\t51821\toff\n" + testPeerB + "\t(none)\t(none)\t10.1.0.2/32\t0\t0\t0\toff\n",
+	}
+	families := gather(t, NewCollectorWithRunner(cfg, runner))
+
+	for _, name := range []string{"wireguard_peers_total", "wireguard_peer_bytes_sent", "wireguard_peer_info"} {
+		family := families[name]
+		if family == nil {
+			t.Fatalf("%s missing", name)
+		}
+		for _, m := range family.GetMetric() {
+			labels := labelMap(m)
+			if labels["node"] != "node1" {
+				t.Errorf("%s %v: node label = %q, want node1", name, labels, labels["node"])
+			}
+			if _, exists := labels["role"]; !exists {
+				t.Errorf("%s %v: role label missing", name, labels)
+			}
+		}
+	}
+
+	if findMetric(families["wireguard_peers_total"], map[string]string{"interface": "wg0", "site": "fra", "role": "hub"}) == nil {
+		t.Error("wg0 series without its custom labels")
+	}
+	if findMetric(families["wireguard_peers_total"], map[string]string{"interface": "wg1", "site": "ams", "role": ""}) == nil {
+		t.Error("wg1 series without an empty role label")
+	}
+	if findMetric(families["wireguard_tool_info"], map[string]string{"version": "1.0.20210914"}) == nil {
+		t.Error("tool version not reported")
+	}
+}
+
+// Overlapping scrapes build their own snapshots, run with -race to catch shared state
+func TestConcurrentCollect(t *testing.T) {
+	runner := fakeRunner{
+		"--version":       "wireguard-tools v1.0.20210914\n",
+		"show interfaces": "wg0\n",
+		"show wg0 dump": "PRIV\tPUB0\t51820\toff\n" +
+			testPeerA + "\t(none)\t1.2.3.4:5555\t10.0.0.2/32\t1700000000\t10\t20\t25\n" +
+			testPeerB + "\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff\n",
+	}
+	c := NewCollectorWithRunner(testConfig(), runner)
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+
+	const scrapes = 20
+	results := make(chan []float64, scrapes)
+	errs := make(chan error, scrapes)
+	var wg sync.WaitGroup
+	for i := 0; i < scrapes; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			families, err := reg.Gather()
+			if err != nil {
+				errs <- err
+				return
+			}
+			var values []float64
+			for _, family := range families {
+				switch family.GetName() {
+				case "wireguard_peers_total", "wireguard_peer_bytes_sent", "wireguard_peer_bytes_received":
+					for _, m := range family.GetMetric() {
+						values = append(values, m.GetGauge().GetValue())
+					}
+				}
+			}
+			results <- values
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("gather failed: %v", err)
+	}
+	var first []float64
+	for values := range results {
+		if first == nil {
+			first = values
+			continue
+		}
+		if !slices.Equal(values, first) {
+			t.Errorf("inconsistent scrape: %v, want %v", values, first)
+		}
+	}
+	if len(first) != 5 {
+		t.Errorf("got %d values %v, want 5", len(first), first)
+	}
+}
+
+func TestPeerInfoLabelDenylist(t *testing.T) {
+	cfg := testConfig()
+	cfg.LabelDenylist = []string{"latest_handshake", "public_key"}
+
+	runner := fakeRunner{
+		"show interfaces": "wg0\n",
+		"show wg0 dump":   "PRIV\tPUB0\t51820\toff\n" + testPeerA + "\t(none)\t1.2.3.4:5555\t10.0.0.2/32\t1700000000\t10\t20\toff\n",
+	}
+	families := gather(t, NewCollectorWithRunner(cfg, runner))
+
+	info := findMetric(families["wireguard_peer_info"], map[string]string{"interface": "wg0"})
+	if info == nil {
+		t.Fatal("wireguard_peer_info missing")
+	}
+	labels := labelMap(info)
+	if labels["latest_handshake"] != "" || labels["public_key"] != "" {
+		t.Errorf("denylisted labels not hidden: %v", labels)
+	}
+	if labels["endpoint"] != "1.2.3.4:5555" {
+		t.Errorf("endpoint = %q, want it unchanged", labels["endpoint"])
+	}
+}
+
+func TestBucketAndSubnetLabelDenylist(t *testing.T) {
+	cfg := testConfig()
+	cfg.LabelDenylist = []string{"bucket", "subnet"}
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	cfg.TrackedSubnetNets = []*net.IPNet{subnet}
+
+	runner := fakeRunner{
+		"show interfaces": "wg0\n",
+		"show wg0 dump":   "PRIV\tPUB0\t51820\toff\n" + testPeerA + "\t(none)\t(none)\t10.0.0.2/32\t0\t0\t0\toff\n",
+	}
+	families := gather(t, NewCollectorWithRunner(cfg, runner))
+
+	for name, label := range map[string]string{"wireguard_peers_handshake_age_bucket": "bucket", "wireguard_subnet_peers": "subnet"} {
+		family := families[name]
+		if family == nil {
+			t.Fatalf("%s missing", name)
+		}
+		for _, m := range family.GetMetric() {
+			value := labelMap(m)[label]
+			if value == "never" || value == "10.0.0.0/24" || len(value) != 16 {
+				t.Errorf("%s: %s label %q is not hashed", name, label, value)
+			}
+		}
+	}
+}
+
+func TestCollectorCustomLabelsOnEveryPeer(t *testing.T) {
+	cfg := testConfig()
+	cfg.InterfaceLabels = map[string]map[string]string{
+		"wg0": {"site": "fra", "role": "hub"},
+	}
+
+	runner := fakeRunner{
+		"show interfaces": "wg0\n",
+		"show wg0 dump": "PRIV\tPUB0\t51820\toff\n" +
+			testPeerA + "\t(none)\t1.2.3.4:5555\t10.0.0.2/32\t0\t10\t20\toff\n" +
+			testPeerB + "\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff\n",
+	}
+	families := gather(t, NewCollectorWithRunner(cfg, runner))
+
+	family := families["wireguard_peer_bytes_sent"]
+	if family == nil || len(family.GetMetric()) != 2 {
+		t.Fatalf("want 2 peer series, got %v", family)
+	}
+	for _, m := range family.GetMetric() {
+		labels := labelMap(m)
+		if labels["site"] != "fra" || labels["role"] != "hub" {
+			t.Errorf("peer series %v without both custom labels", labels)
+		}
+	}
+}
+
+// wg prints "(none)" for a peer without allowed IPs, which is no allowed IP at all
+func TestPeerWithoutAllowedIPs(t *testing.T) {
+	runner := fakeRunner{
+		"show interfaces": "wg0\n",
+		"show wg0 dump":   "PRIV\tPUB0\t51820\toff\n" + testPeerA + "\t(none)\t(none)\t(none)\t0\t0\t0\toff\n",
+	}
+
+	iface, err := newFakeClient(runner).ParseInterfaceData(context.Background(), "wg0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(iface.Peers) != 1 || len(iface.Peers[0].AllowedIPs) != 0 {
+		t.Fatalf("peers = %+v, want one without allowed IPs", iface.Peers)
+	}
+
+	families := gather(t, NewCollectorWithRunner(testConfig(), runner))
+	for _, name := range []string{"wireguard_peer_allowed_ips_count", "wireguard_peer_allowed_ips_v4_count", "wireguard_peer_allowed_ips_v6_count"} {
+		m := findMetric(families[name], map[string]string{"interface": "wg0"})
+		if m == nil {
+			t.Fatalf("%s missing", name)
+		}
+		if value := m.GetGauge().GetValue(); value != 0 {
+			t.Errorf("%s = %v, want 0", name, value)
+		}
+	}
+	info := findMetric(families["wireguard_peer_info"], map[string]string{"interface": "wg0"})
+	if info == nil || labelMap(info)["allowed_ips"] != "" {
+		t.Errorf("peer info = %v, want empty allowed_ips", info)
+	}
+}
+
+// A port wg printed but that is not valid is left out instead of being reported as 0
+func TestInvalidListeningPortSkipped(t *testing.T) {
+	runner := fakeRunner{
+		"show interfaces": "wg0 wg1\n",
+		"show wg0 dump":   "PRIV\tPUB0\t99999\toff\n",
+		"show wg1 dump":   "PRIV\tPUB1\t51820\toff\n",
+	}
+	families := gather(t, NewCollectorWithRunner(testConfig(), runner))
+
+	family := families["wireguard_interface_listening_port"]
+	if findMetric(family, map[string]string{"interface": "wg0"}) != nil {
+		t.Error("listening port reported for an invalid port")
+	}
+	m := findMetric(family, map[string]string{"interface": "wg1"})
+	if m == nil || m.GetGauge().GetValue() != 51820 {
+		t.Errorf("wg1 listening port = %v, want 51820", m)
+	}
+}
@@ -0,0 +1,287 @@
+package wireguard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Running byte totals per peer. WireGuard resets its counters when an interface restarts,
+// these totals carry on across such resets and, with a state file, across exporter restarts
+type byteCounters struct {
+	mu    sync.Mutex
+	peers map[string]*peerCounters // Keyed by interface name and public key
+}
+
+type peerCounters struct {
+	LastReceived  uint64 `json:"last_received"` // Absolute values reported by wg on the previous scrape
+	LastSent      uint64 `json:"last_sent"`
+	TotalReceived uint64 `json:"total_received"` // Running totals
+	TotalSent     uint64 `json:"total_sent"`
+	DeltaReceived uint64 `json:"-"` // Growth since the previous scrape, 0 on the first one
+	DeltaSent     uint64 `json:"-"`
+}
+
+func newByteCounters() *byteCounters {
+	return &byteCounters{
+		peers: make(map[string]*peerCounters),
+	}
+}
+
+// Load the totals saved by a previous run. A missing state file is not an error
+func (b *byteCounters) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	peers := make(map[string]*peerCounters)
+	if err := json.Unmarshal(data, &peers); err != nil {
+		return fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.peers = peers
+	return nil
+}
+
+// Save the totals, writing to a temporary file first so a crash never leaves a partial state file
+func (b *byteCounters) save(path string) error {
+	b.mu.Lock()
+	data, err := json.Marshal(b.peers)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Record the absolute values reported by wg and return the running totals and the growth
+// since the previous scrape. A value lower than the previous one means the interface was
+// restarted, then the whole new value counts as growth
+func (b *byteCounters) update(ifaceName, publicKey string, received, sent uint64) peerCounters {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := ifaceName + "/" + publicKey
+	p, exists := b.peers[key]
+	if !exists {
+		p = &peerCounters{}
+		b.peers[key] = p
+	}
+
+	p.DeltaReceived = counterDelta(p.LastReceived, received)
+	p.DeltaSent = counterDelta(p.LastSent, sent)
+	p.TotalReceived += p.DeltaReceived
+	p.TotalSent += p.DeltaSent
+	p.LastReceived = received
+	p.LastSent = sent
+
+	// There is no previous scrape to compare with, the totals still count everything
+	if !exists {
+		p.DeltaReceived = 0
+		p.DeltaSent = 0
+	}
+
+	return *p
+}
+
+// Forget the peers that are gone, like transferTracker.prune. The state file only keeps
+// the totals of current peers
+func (b *byteCounters) prune(seen, keep map[string]bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	prunePeers(b.peers, seen, keep)
+}
+
+func counterDelta(last, current uint64) uint64 {
+	if current < last {
+		// Counter reset
+		return current
+	}
+	return current - last
+}
+
+// Last known endpoint and number of endpoint changes per peer, used to detect roaming
+type endpointTracker struct {
+	mu    sync.Mutex
+	peers map[string]*peerEndpoint // Keyed by interface name and public key
+}
+
+type peerEndpoint struct {
+	endpoint string
+	changes  uint64
+}
+
+func newEndpointTracker() *endpointTracker {
+	return &endpointTracker{
+		peers: make(map[string]*peerEndpoint),
+	}
+}
+
+// Record the endpoint of a peer and return how many times it changed so far.
+// The first observation only seeds the state, and an empty endpoint is not an observation
+func (t *endpointTracker) update(ifaceName, publicKey, endpoint string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := ifaceName + "/" + publicKey
+	p, exists := t.peers[key]
+	if !exists {
+		p = &peerEndpoint{}
+		t.peers[key] = p
+	}
+
+	if endpoint != "" {
+		if p.endpoint != "" && p.endpoint != endpoint {
+			p.changes++
+		}
+		p.endpoint = endpoint
+	}
+	return p.changes
+}
+
+// Forget the peers that are gone, like transferTracker.prune
+func (t *endpointTracker) prune(seen, keep map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prunePeers(t.peers, seen, keep)
+}
+
+// Time of the last byte counter change per peer, to spot tunnels that are up but carry no traffic
+type transferTracker struct {
+	mu    sync.Mutex
+	peers map[string]*peerTransfer // Keyed by interface name and public key
+}
+
+type peerTransfer struct {
+	received uint64
+	sent     uint64
+	changed  time.Time
+}
+
+func newTransferTracker() *transferTracker {
+	return &transferTracker{
+		peers: make(map[string]*peerTransfer),
+	}
+}
+
+// Record the byte counters of a peer and return how long ago they last changed.
+// The first observation counts as a change
+func (t *transferTracker) update(ifaceName, publicKey string, received, sent uint64, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := ifaceName + "/" + publicKey
+	p, exists := t.peers[key]
+	if !exists || p.received != received || p.sent != sent {
+		p = &peerTransfer{received: received, sent: sent, changed: now}
+		t.peers[key] = p
+	}
+	return now.Sub(p.changed)
+}
+
+// Forget the peers that are gone. seen holds the keys of the peers updated by the last
+// scrape, peers of the interfaces in keep are left alone, e.g. when fetching them failed
+func (t *transferTracker) prune(seen, keep map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prunePeers(t.peers, seen, keep)
+}
+
+// Delete the entries keyed by interface name and public key that are not in seen, unless
+// their interface is in keep
+func prunePeers[V any](peers map[string]V, seen, keep map[string]bool) {
+	for key := range peers {
+		ifaceName, _, _ := strings.Cut(key, "/")
+		if !seen[key] && !keep[ifaceName] {
+			delete(peers, key)
+		}
+	}
+}
+
+// Consecutive fetch failures per interface. After threshold failures in a row the circuit
+// opens and the interface is skipped for the cooldown, then one fetch is let through as a
+// probe: success closes the circuit, another failure opens it again
+type circuitBreaker struct {
+	mu         sync.Mutex
+	interfaces map[string]*interfaceCircuit
+}
+
+type interfaceCircuit struct {
+	failures  int
+	openUntil time.Time // Zero while the circuit is closed
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{
+		interfaces: make(map[string]*interfaceCircuit),
+	}
+}
+
+// Whether the circuit of the interface is open, i.e. it must not be fetched
+func (b *circuitBreaker) open(ifaceName string, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	circuit, exists := b.interfaces[ifaceName]
+	return exists && now.Before(circuit.openUntil)
+}
+
+// Record the outcome of a fetch and return whether it opened the circuit
+func (b *circuitBreaker) record(ifaceName string, ok bool, threshold int, cooldown time.Duration, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ok {
+		delete(b.interfaces, ifaceName)
+		return false
+	}
+
+	circuit, exists := b.interfaces[ifaceName]
+	if !exists {
+		circuit = &interfaceCircuit{}
+		b.interfaces[ifaceName] = circuit
+	}
+	circuit.failures++
+	if circuit.failures < threshold {
+		return false
+	}
+	circuit.openUntil = now.Add(cooldown)
+	return true
+}
+
+// Forget the interfaces that are gone
+func (b *circuitBreaker) prune(interfaces []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ifaceName := range b.interfaces {
+		if !slices.Contains(interfaces, ifaceName) {
+			delete(b.interfaces, ifaceName)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package wireguard
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrackersPrune(t *testing.T) {
+	counters := newByteCounters()
+	endpoints := newEndpointTracker()
+	transfers := newTransferTracker()
+	for _, key := range []string{"wg0/A", "wg0/B", "wg1/C"} {
+		ifaceName, publicKey := key[:3], key[4:]
+		counters.update(ifaceName, publicKey, 1, 1)
+		endpoints.update(ifaceName, publicKey, "1.2.3.4:51820")
+		transfers.update(ifaceName, publicKey, 1, 1, time.Now())
+	}
+
+	// B is gone from wg0, fetching wg1 failed
+	seen := map[string]bool{"wg0/A": true}
+	keep := map[string]bool{"wg1": true}
+	counters.prune(seen, keep)
+	endpoints.prune(seen, keep)
+	transfers.prune(seen, keep)
+
+	for name, peers := range map[string]int{"counters": len(counters.peers), "endpoints": len(endpoints.peers), "transfers": len(transfers.peers)} {
+		if peers != 2 {
+			t.Errorf("%s: %d peers left, want 2", name, peers)
+		}
+	}
+	if _, exists := counters.peers["wg0/B"]; exists {
+		t.Error("counters of the removed peer kept")
+	}
+	if _, exists := endpoints.peers["wg1/C"]; !exists {
+		t.Error("endpoint of the peer on the failed interface dropped")
+	}
+}
+
+// Peers removed from wg disappear from the state file
+func TestStateFileDropsRemovedPeers(t *testing.T) {
+	cfg := testConfig()
+	cfg.StateFile = filepath.Join(t.TempDir(), "state.json")
+
+	runner := fakeRunner{
+		"show interfaces": "wg0\n",
+		"show wg0 dump": "PRIV\tPUB0\t51820\toff\n" +
+			testPeerA + "\t(none)\t(none)\t10.0.0.2/32\t0\t10\t20\toff\n" +
+			testPeerB + "\t(none)\t(none)\t10.0.0.3/32\t0\t30\t40\toff\n",
+	}
+	c := NewCollectorWithRunner(cfg, runner)
+	gather(t, c)
+
+	runner["show wg0 dump"] = "PRIV\tPUB0\t51820\toff\n" + testPeerA + "\t(none)\t(none)\t10.0.0.2/32\t0\t15\t25\toff\n"
+	gather(t, c)
+
+	data, err := os.ReadFile(cfg.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var peers map[string]peerCounters
+	if err := json.Unmarshal(data, &peers); err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers["wg0/"+testPeerA].TotalReceived != 15 {
+		t.Errorf("state file peers = %+v, want only peer A", peers)
+	}
+}
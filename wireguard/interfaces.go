@@ -1,68 +1,131 @@
-package wireguard
-
-import (
-	"context"
-	"fmt"
-	"log/slog"
-	"os/exec"
-	"strings"
-	"time"
-)
-
-// Discover all interfaces and filters them using the deny-list
-func DiscoverInterfaces(wgCommandPath string, denylist []string) ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, wgCommandPath, "show", "interfaces")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute wg show interfaces: %w", err)
-	}
-
-	// Each line is an interface name
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var interfaces []string
-	
-	// Create a map for fast denylist lookup
-	denyMap := make(map[string]bool)
-	for _, denied := range denylist {
-		denyMap[denied] = true
-	}
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		
-		// Validate interface name to prevent command injection
-		if !isValidInterfaceName(line) {
-			slog.Warn("Invalid interface name detected, skipping", "interface", line)
-			continue
-		}
-
-		// Check if interface is in deny-list
-		if !denyMap[line] {
-			interfaces = append(interfaces, line)
-		}
-	}
-
-	slog.Info("Discovered WireGuard interfaces", "count", len(interfaces), "filtered", len(lines)-len(interfaces))
-	return interfaces, nil
-}
-
-// isValidInterfaceName validates interface name to prevent command injection
-// Interface names should be alphanumeric with underscores and hyphens
-func isValidInterfaceName(name string) bool {
-	if len(name) == 0 || len(name) > 15 { // Linux interface name limit
-		return false
-	}
-	for _, r := range name {
-		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_') {
-			return false
-		}
-	}
-	return true
-}
-
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Network interfaces in sysfs, only present on Linux
+const sysfsNetPath = "/sys/class/net"
+
+// Read the MTU of a network interface from sysfs. wg doesn't report it
+func readInterfaceMTU(ifaceName string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(sysfsNetPath, ifaceName, "mtu"))
+	if err != nil {
+		return 0, err
+	}
+	mtu, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid MTU %q: %w", strings.TrimSpace(string(data)), err)
+	}
+	return mtu, nil
+}
+
+// Resolve the executable of the wg command (wg itself or a wrapper) to an absolute path,
+// either from PATH or as given
+func ResolveWGCommand(wgCommand []string) (string, error) {
+	resolved, err := exec.LookPath(wgCommand[0])
+	if err != nil {
+		return "", fmt.Errorf("wg command %q not found or not executable: %w", wgCommand[0], err)
+	}
+
+	absPath, err := filepath.Abs(resolved)
+	if err != nil {
+		return resolved, nil
+	}
+	return absPath, nil
+}
+
+var toolVersionPattern = regexp.MustCompile(`v?(\d+(?:\.\d+)+)`)
+
+// Run "wg --version" and extract the version, e.g. "1.0.20210914" from
+// "wireguard-tools v1.0.20210914 - https://git.zx2c4.com/wireguard-tools/".
+// Returns "unknown" if the command fails or prints no version
+func (w *WGClient) ToolVersion() string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := w.runOnce(ctx, "--version")
+	if err != nil {
+		w.log().Warn("Failed to get wg version", "error", err)
+		return "unknown"
+	}
+
+	matches := toolVersionPattern.FindStringSubmatch(string(output))
+	if matches == nil {
+		w.log().Warn("Failed to parse wg version", "output", strings.TrimSpace(string(output)))
+		return "unknown"
+	}
+	return matches[1]
+}
+
+// Discover all interfaces and filters them using the deny-list, either literal names
+// or compiled patterns. Also returns the number of interfaces found before filtering
+func (w *WGClient) DiscoverInterfaces(ctx context.Context, denylist []string, denyPatterns []*regexp.Regexp) ([]string, []string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	output, err := w.run(ctx, "show", "interfaces")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute wg show interfaces: %w", err)
+	}
+
+	// Interface names are separated by spaces on one line, or one per line on some systems
+	names := strings.Fields(string(output))
+	var interfaces, denied []string
+	
+	// Create a map for fast denylist lookup
+	denyMap := make(map[string]bool)
+	for _, denied := range denylist {
+		denyMap[denied] = true
+	}
+
+	for _, name := range names {
+		// Validate interface name to prevent command injection
+		if !isValidInterfaceName(name) {
+			w.log().Warn("Invalid interface name detected, skipping", "interface", name)
+			continue
+		}
+
+		// Check if interface is in deny-list
+		if denyMap[name] || matchesAny(denyPatterns, name) {
+			denied = append(denied, name)
+		} else {
+			interfaces = append(interfaces, name)
+		}
+	}
+
+	w.log().Info("Discovered WireGuard interfaces", "count", len(interfaces), "filtered", len(denied))
+	return interfaces, denied, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidInterfaceName validates interface name to prevent command injection
+// Interface names should be alphanumeric with underscores and hyphens
+func isValidInterfaceName(name string) bool {
+	if len(name) == 0 || len(name) > 15 { // Linux interface name limit
+		return false
+	}
+	for _, r := range name {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
@@ -0,0 +1,49 @@
+package wireguard
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestDiscoverInterfaces(t *testing.T) {
+	tests := []struct {
+		name         string
+		output       string
+		denylist     []string
+		denyPatterns []*regexp.Regexp
+		want         []string
+		wantDenied   []string
+	}{
+		{name: "single line", output: "wg0 wg1\n", want: []string{"wg0", "wg1"}},
+		{name: "one per line", output: "wg0\nwg1\n", want: []string{"wg0", "wg1"}},
+		{name: "no interfaces", output: "\n"},
+		{name: "literal denylist", output: "wg0 wg1\n", denylist: []string{"wg1"}, want: []string{"wg0"}, wantDenied: []string{"wg1"}},
+		{name: "pattern denylist", output: "wg0 wg-test1 wg-test2\n", denyPatterns: []*regexp.Regexp{regexp.MustCompile(`^wg-test`)}, want: []string{"wg0"}, wantDenied: []string{"wg-test1", "wg-test2"}},
+		{name: "invalid name skipped", output: "wg0 wg1;reboot\n", want: []string{"wg0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(fakeRunner{"show interfaces": tt.output})
+			interfaces, denied, err := client.DiscoverInterfaces(context.Background(), tt.denylist, tt.denyPatterns)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(interfaces, tt.want) {
+				t.Errorf("interfaces = %v, want %v", interfaces, tt.want)
+			}
+			if !slices.Equal(denied, tt.wantDenied) {
+				t.Errorf("denied = %v, want %v", denied, tt.wantDenied)
+			}
+		})
+	}
+}
+
+func TestDiscoverInterfacesCommandFails(t *testing.T) {
+	client := newFakeClient(fakeRunner{})
+	if _, _, err := client.DiscoverInterfaces(context.Background(), nil, nil); err == nil {
+		t.Fatal("expected an error when wg show interfaces fails")
+	}
+}
@@ -1,198 +1,656 @@
-package wireguard
-
-import (
-	"context"
-	"fmt"
-	"log/slog"
-	"os"
-	"os/exec"
-	"regexp"
-	"strconv"
-	"strings"
-	"time"
-)
-
-func ParseInterfaceData(wgCommandPath, interfaceName string) (*Interface, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Validate interface name for security
-	if !isValidInterfaceName(interfaceName) {
-		return nil, fmt.Errorf("invalid interface name: %s", interfaceName)
-	}
-
-	cmd := exec.CommandContext(ctx, wgCommandPath, "show", interfaceName, "dump")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute wg show %s dump: %w", interfaceName, err)
-	}
-
-	outputStr := string(output)
-
-	// Parse the dump format which is tab-separated
-	// Format: <interface private key> <interface public key> <listening port> <fwmark>
-	// Format per peer: <public key> "(none)" <endpoint> <allowed ips> <last handshake> <rx bytes> <tx bytes> <persistent keepalive>
-	
-	dumpLines := strings.Split(strings.TrimSpace(outputStr), "\n")
-	if len(dumpLines) == 0 {
-		return nil, fmt.Errorf("no data returned from wg show")
-	}
-
-	// First line is the interface
-	interfaceParts := strings.Fields(dumpLines[0])
-	if len(interfaceParts) < 4 {
-		return nil, fmt.Errorf("invalid interface dump format")
-	}
-
-	iface := &Interface{
-		Name:         interfaceName,
-		PublicKey:    interfaceParts[1],
-		ListeningPort: 0,
-		Peers:        []Peer{},
-	}
-
-	// Parse listening port
-	if len(interfaceParts) >= 2 {
-		if port, err := strconv.Atoi(interfaceParts[2]); err == nil {
-			iface.ListeningPort = port
-		}
-	}
-
-	slog.Debug("Parsed listening port", "interface", interfaceName, "port", iface.ListeningPort)
-
-	// Remaining lines are peers
-	for i := 1; i < len(dumpLines); i++ {
-		peerParts := strings.Fields(dumpLines[i])
-		if len(peerParts) < 7 {
-			continue
-		}
-
-		peer := Peer{
-			PublicKey:      peerParts[0],
-			Endpoint:       "",
-			AllowedIPs:     []string{},
-			LatestHandshake: time.Time{},
-			BytesSent:      0,
-			BytesReceived:  0,
-		}
-
-		// Parse endpoint (can be empty)
-		if peerParts[2] != "(none)" {
-			peer.Endpoint = peerParts[2]
-		}
-
-		// Parse allowed IPs
-		allowedIPs := strings.Split(peerParts[3], ",")
-		for _, ip := range allowedIPs {
-			peer.AllowedIPs = append(peer.AllowedIPs, strings.TrimSpace(ip))
-		}
-
-		// Parse latest handshake (Unix timestamp)
-		if peerParts[4] != "0" {
-			if timestamp, err := strconv.ParseInt(peerParts[4], 10, 64); err == nil && timestamp > 0 {
-				peer.LatestHandshake = time.Unix(timestamp, 0)
-			}
-		}
-
-		// Parse received bytes
-		if bytes, err := strconv.ParseUint(peerParts[5], 10, 64); err == nil {
-			peer.BytesReceived = bytes
-		}
-
-		// Parse sent bytes
-		if bytes, err := strconv.ParseUint(peerParts[6], 10, 64); err == nil {
-			peer.BytesSent = bytes
-		}
-
-		slog.Debug("Parsed peer data", "interface", interfaceName, "peer", peer)
-		iface.Peers = append(iface.Peers, peer)
-	}
-
-	slog.Debug("Parsed interface data", "interface", interfaceName, "peers", len(iface.Peers))
-	return iface, nil
-}
-
-// ParseWireGuardConfigFile parses a WireGuard config file and extracts display names
-// mapped by public key. Returns a map of public key -> display name.
-func ParseWireGuardConfigFile(configPath string) (map[string]string, error) {
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	displayNames := make(map[string]string)
-	lines := strings.Split(string(data), "\n")
-	
-	var inPeerSection bool
-	var currentPublicKey string
-	var currentDisplayName string
-	
-	// Regex to match "# display-name = <value>" or "#display-name = <value>" (with or without space after #)
-	// Supports both "display-name" and "display_name" formats
-	displayNameRegex := regexp.MustCompile(`(?i)^\s*#\s*display[-_]name\s*=\s*(.+)$`)
-	// Regex to match "PublicKey = <value>"
-	publicKeyRegex := regexp.MustCompile(`(?i)^\s*PublicKey\s*=\s*(.+)$`)
-	
-	for _, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		
-		// Check if we're entering a [Peer] section
-		if strings.HasPrefix(trimmedLine, "[Peer]") {
-			// If we were in a previous peer section and found a display name, save it
-			if inPeerSection && currentPublicKey != "" && currentDisplayName != "" {
-				displayNames[currentPublicKey] = currentDisplayName
-			}
-			
-			inPeerSection = true
-			currentPublicKey = ""
-			currentDisplayName = ""
-			continue
-		}
-		
-		// Check if we're leaving the peer section (entering another section)
-		if strings.HasPrefix(trimmedLine, "[") && trimmedLine != "[Peer]" {
-			// Save any display name we found before leaving
-			if currentPublicKey != "" && currentDisplayName != "" {
-				displayNames[currentPublicKey] = currentDisplayName
-			}
-			inPeerSection = false
-			currentPublicKey = ""
-			currentDisplayName = ""
-			continue
-		}
-		
-		if inPeerSection {
-			// Check for display-name comment
-			if matches := displayNameRegex.FindStringSubmatch(trimmedLine); matches != nil {
-				displayName := strings.TrimSpace(matches[1])
-				if displayName != "" {
-					currentDisplayName = displayName
-					// If we already have the public key, save immediately
-					if currentPublicKey != "" {
-						displayNames[currentPublicKey] = displayName
-					}
-				}
-			}
-			
-			// Check for PublicKey
-			if matches := publicKeyRegex.FindStringSubmatch(trimmedLine); matches != nil {
-				publicKey := strings.TrimSpace(matches[1])
-				if publicKey != "" {
-					currentPublicKey = publicKey
-					// If we already have the display name, save immediately
-					if currentDisplayName != "" {
-						displayNames[publicKey] = currentDisplayName
-					}
-				}
-			}
-		}
-	}
-	
-	// Handle the last peer section if we ended in one
-	if inPeerSection && currentPublicKey != "" && currentDisplayName != "" {
-		displayNames[currentPublicKey] = currentDisplayName
-	}
-	
-	slog.Debug("Parsed config file", "path", configPath, "display_names_count", len(displayNames))
-	return displayNames, nil
-}
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of fields of a peer line in "wg show dump" output
+const dumpPeerFields = 8
+
+// ListeningPort of an interface whose port wg printed but could not be parsed
+const unknownListeningPort = -1
+
+func (w *WGClient) ParseInterfaceData(ctx context.Context, interfaceName string) (*Interface, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Validate interface name for security
+	if !isValidInterfaceName(interfaceName) {
+		return nil, fmt.Errorf("invalid interface name: %s", interfaceName)
+	}
+
+	output, err := w.run(ctx, "show", interfaceName, "dump")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute wg show %s dump: %w", interfaceName, err)
+	}
+
+	outputStr := string(output)
+
+	// Parse the dump format which is tab-separated
+	// Format: <interface private key> <interface public key> <listening port> <fwmark>
+	// Format per peer: <public key> "(none)" <endpoint> <allowed ips> <last handshake> <rx bytes> <tx bytes> <persistent keepalive>
+	
+	// An interface that was created but never configured can return an empty dump.
+	// It still exists, so report it with no peers instead of dropping it
+	if strings.TrimSpace(outputStr) == "" {
+		w.log().Debug("Empty dump, interface exists but is unconfigured", "interface", interfaceName)
+		return &Interface{
+			Name:  interfaceName,
+			Peers: []Peer{},
+		}, nil
+	}
+
+	dumpLines := strings.Split(strings.TrimSpace(outputStr), "\n")
+
+	// First line is the interface
+	interfaceParts := dumpFields(dumpLines[0])
+	if len(interfaceParts) < 4 {
+		return nil, fmt.Errorf("invalid interface dump format: expected 4 fields, got %d", len(interfaceParts))
+	}
+
+	iface := &Interface{
+		Name:         interfaceName,
+		PublicKey:    interfaceParts[1],
+		ListeningPort: 0,
+		Peers:        []Peer{},
+	}
+
+	// No key configured yet
+	iface.PublicKey = dumpValue(iface.PublicKey)
+
+	// Parse listening port, "off" or a decimal or hex value. A port that doesn't parse or
+	// is out of range is logged and marked unknown, it usually means the dump format changed
+	if port, ok := parseListeningPort(w.log(), interfaceName, interfaceParts[2]); ok {
+		iface.ListeningPort = port
+	} else {
+		iface.ListeningPort = unknownListeningPort
+	}
+
+	// Parse fwmark, "off" or a hex value like "0xca6c"
+	if interfaceParts[3] != "off" {
+		if fwmark, err := strconv.ParseUint(interfaceParts[3], 0, 32); err == nil {
+			iface.FwMark = uint32(fwmark)
+		}
+	}
+
+	w.log().Debug("Parsed listening port", "interface", interfaceName, "port", iface.ListeningPort, "fwmark", iface.FwMark)
+
+	// Remaining lines are peers
+	for i := 1; i < len(dumpLines); i++ {
+		peerParts := dumpFields(dumpLines[i])
+		if len(peerParts) < dumpPeerFields {
+			// Truncated output, skip the line instead of emitting a half-populated peer
+			w.log().Debug("Skipping malformed peer line", "interface", interfaceName, "fields", len(peerParts), "line", redactPresharedKey(peerParts))
+			continue
+		}
+
+		// Any column can hold the "(none)" sentinel depending on the wg version, so
+		// every field is checked on its own and "(none)" reads as empty
+		for j := range peerParts {
+			peerParts[j] = dumpValue(peerParts[j])
+		}
+
+		peer := Peer{
+			PublicKey:      peerParts[0],
+			Endpoint:       "",
+			AllowedIPs:     []string{},
+			LatestHandshake: time.Time{},
+			BytesSent:      0,
+			BytesReceived:  0,
+		}
+
+		// Only record whether a preshared key is set
+		peer.HasPresharedKey = peerParts[1] != ""
+
+		// Parse endpoint (can be empty)
+		if peerParts[2] != "" {
+			setEndpoint(&peer, peerParts[2])
+		}
+
+		// Parse allowed IPs, empty for a peer without any ("(none)" in the dump)
+		if peerParts[3] != "" {
+			for _, ip := range strings.Split(peerParts[3], ",") {
+				peer.AllowedIPs = append(peer.AllowedIPs, strings.TrimSpace(ip))
+			}
+		}
+
+		// Parse latest handshake (Unix timestamp)
+		if peerParts[4] != "" && peerParts[4] != "0" {
+			if timestamp, err := strconv.ParseInt(peerParts[4], 10, 64); err == nil && timestamp > 0 {
+				peer.LatestHandshake = time.Unix(timestamp, 0)
+			}
+		}
+
+		// Parse received bytes
+		if bytes, err := strconv.ParseUint(peerParts[5], 10, 64); err == nil {
+			peer.BytesReceived = bytes
+		}
+
+		// Parse sent bytes
+		if bytes, err := strconv.ParseUint(peerParts[6], 10, 64); err == nil {
+			peer.BytesSent = bytes
+		}
+
+		// Parse persistent keepalive, "off" or seconds
+		if peerParts[7] != "off" && peerParts[7] != "" {
+			if keepalive, err := strconv.ParseUint(peerParts[7], 10, 16); err == nil {
+				peer.PersistentKeepalive = int(keepalive)
+			}
+		}
+
+		// Columns appended by newer wg versions are kept aside, the known ones keep their index
+		if len(peerParts) > dumpPeerFields {
+			peer.ExtraFields = peerParts[dumpPeerFields:]
+		}
+
+		w.log().Debug("Parsed peer data", "interface", interfaceName, "peer", peer)
+		iface.Peers = append(iface.Peers, peer)
+	}
+
+	w.log().Debug("Parsed interface data", "interface", interfaceName, "peers", len(iface.Peers))
+	return iface, nil
+}
+
+// Split a dump line into its tab-separated columns. Unlike splitting on any whitespace,
+// a value containing a space stays in its column
+func dumpFields(line string) []string {
+	return strings.Split(strings.TrimRight(line, "\r"), "\t")
+}
+
+// Value of a dump field, empty for the "(none)" sentinel
+func dumpValue(field string) string {
+	if field == "(none)" {
+		return ""
+	}
+	return field
+}
+
+// Join the fields of a dump peer line for logging, with the preshared key hidden
+func redactPresharedKey(peerParts []string) string {
+	fields := slices.Clone(peerParts)
+	if len(fields) > 1 && dumpValue(fields[1]) != "" {
+		fields[1] = "(hidden)"
+	}
+	return strings.Join(fields, " ")
+}
+
+// Parse the listening port field of a dump, 0 if it is "off". Not ok if it is not a number
+// or not a valid port
+func parseListeningPort(logger *slog.Logger, interfaceName, value string) (int, bool) {
+	if value == "off" {
+		return 0, true
+	}
+
+	port, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		logger.Debug("Failed to parse listening port", "interface", interfaceName, "value", value, "error", err)
+		return 0, false
+	}
+	if port < 0 || port > 65535 {
+		logger.Warn("Ignoring out of range listening port", "interface", interfaceName, "port", port)
+		return 0, false
+	}
+	return int(port), true
+}
+
+// Set the endpoint of a peer along with its IP, zone, port and address family
+func setEndpoint(peer *Peer, endpoint string) {
+	ip, zone, port := splitEndpoint(endpoint)
+
+	peer.Endpoint = endpoint
+	peer.EndpointIP = ip
+	peer.EndpointZone = zone
+	peer.EndpointPort = 0
+	if n, err := strconv.Atoi(port); err == nil {
+		peer.EndpointPort = n
+	}
+	peer.EndpointFamily = addressFamily(ip)
+}
+
+// Split an endpoint into IP, IPv6 zone and port, handling bracketed IPv6 like
+// "[2001:db8::1]:51820" and zones like "[fe80::1%eth0]:51820". Returns the endpoint
+// without brackets as the IP and an empty port if it cannot be split
+func splitEndpoint(endpoint string) (ip, zone, port string) {
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host, port = strings.Trim(endpoint, "[]"), ""
+	}
+
+	ip, zone, _ = strings.Cut(host, "%")
+	return ip, zone, port
+}
+
+// Address family of an IP: "ipv4", "ipv6", or "unknown" for anything else (e.g. hostnames)
+func addressFamily(host string) string {
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "unknown"
+	case ip.To4() != nil:
+		return "ipv4"
+	default:
+		return "ipv6"
+	}
+}
+
+// ParseWireGuardConfigFile parses a WireGuard config file and extracts display names
+// mapped by public key. Returns a map of public key -> display name.
+func ParseWireGuardConfigFile(configPath string) (map[string]string, error) {
+	peers, err := ParseWireGuardConfigPeers(slog.Default(), configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	displayNames := make(map[string]string)
+	for publicKey, peer := range peers {
+		if peer.DisplayName != "" {
+			displayNames[publicKey] = peer.DisplayName
+		}
+	}
+	return displayNames, nil
+}
+
+// ParseWireGuardConfigPeers parses the [Peer] sections of a WireGuard config file.
+// Returns a map of public key -> what the file says about the peer
+func ParseWireGuardConfigPeers(logger *slog.Logger, configPath string) (map[string]ConfigPeer, error) {
+	configFile, err := ParseWireGuardConfig(logger, configPath)
+	if err != nil {
+		return nil, err
+	}
+	return configFile.Peers, nil
+}
+
+// ParseWireGuardConfig parses the interface addresses and the [Peer] sections of a WireGuard config file
+func ParseWireGuardConfig(logger *slog.Logger, configPath string) (*ConfigFile, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	peers := make(map[string]ConfigPeer)
+	var addresses []string
+	lines := strings.Split(string(data), "\n")
+
+	var inPeerSection, inInterfaceSection bool
+	var currentPublicKey string
+	var current ConfigPeer
+
+	// Save the peer section being read, if it had a public key
+	savePeer := func() {
+		if inPeerSection && currentPublicKey != "" {
+			peers[currentPublicKey] = current
+		}
+		currentPublicKey = ""
+		current = ConfigPeer{}
+	}
+
+	// Regex to match "# display-name = <value>" or "#display-name = <value>" (with or without space after #)
+	// Supports both "display-name" and "display_name" formats
+	displayNameRegex := regexp.MustCompile(`(?i)^\s*#\s*display[-_]name\s*=\s*(.+)$`)
+	// Regex to match "PublicKey = <value>"
+	publicKeyRegex := regexp.MustCompile(`(?i)^\s*PublicKey\s*=\s*(.+)$`)
+	// Regex to match "Endpoint = <value>"
+	endpointRegex := regexp.MustCompile(`(?i)^\s*Endpoint\s*=\s*(\S+)`)
+	// Regex to match "Address = <value>[, <value>...]", the line can also be repeated
+	addressRegex := regexp.MustCompile(`(?i)^\s*Address\s*=\s*(.+)$`)
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		// A new section ends the previous peer section
+		if strings.HasPrefix(trimmedLine, "[") {
+			savePeer()
+			inPeerSection = strings.HasPrefix(trimmedLine, "[Peer]")
+			inInterfaceSection = strings.HasPrefix(trimmedLine, "[Interface]")
+			continue
+		}
+
+		if inInterfaceSection {
+			if matches := addressRegex.FindStringSubmatch(trimmedLine); matches != nil {
+				addresses = append(addresses, parseConfigAddresses(logger, configPath, matches[1])...)
+			}
+			continue
+		}
+
+		if !inPeerSection {
+			continue
+		}
+
+		if matches := displayNameRegex.FindStringSubmatch(trimmedLine); matches != nil {
+			if displayName := strings.TrimSpace(matches[1]); displayName != "" {
+				current.DisplayName = displayName
+			}
+		} else if matches := publicKeyRegex.FindStringSubmatch(trimmedLine); matches != nil {
+			currentPublicKey = strings.TrimSpace(matches[1])
+		} else if endpointRegex.MatchString(trimmedLine) {
+			current.HasEndpoint = true
+		}
+	}
+
+	// Handle the last peer section if we ended in one
+	savePeer()
+
+	logger.Debug("Parsed config file", "path", configPath, "peers_count", len(peers), "addresses_count", len(addresses))
+	return &ConfigFile{Addresses: addresses, Peers: peers}, nil
+}
+
+// Addresses of a comma-separated Address value, with or without a prefix length.
+// Anything else, e.g. a trailing comment, is skipped
+func parseConfigAddresses(logger *slog.Logger, configPath, value string) []string {
+	value, _, _ = strings.Cut(value, "#")
+
+	var addresses []string
+	for _, address := range strings.Split(value, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(address); err != nil && net.ParseIP(address) == nil {
+			logger.Debug("Skipping invalid interface address in config file", "path", configPath, "address", address)
+			continue
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// Patterns for the human-readable "wg show <interface>" output
+var (
+	interfacePattern = regexp.MustCompile(`^interface:\s*(\S+)$`)
+	peerPattern      = regexp.MustCompile(`^peer:\s*(\S+)$`)
+	fieldPattern     = regexp.MustCompile(`^([a-z ]+):\s*(.+)$`)
+	durationPattern  = regexp.MustCompile(`(\d+)\s+(year|day|hour|minute|second)s?`)
+	transferPattern  = regexp.MustCompile(`^([\d.]+)\s*(B|KiB|MiB|GiB|TiB|PiB|EiB)\s+received,\s*([\d.]+)\s*(B|KiB|MiB|GiB|TiB|PiB|EiB)\s+sent$`)
+)
+
+// ParseInterfaceDataHuman is the fallback for ParseInterfaceData, it parses the plain
+// "wg show <interface>" output instead of the dump format
+func (w *WGClient) ParseInterfaceDataHuman(ctx context.Context, interfaceName string) (*Interface, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Validate interface name for security
+	if !isValidInterfaceName(interfaceName) {
+		return nil, fmt.Errorf("invalid interface name: %s", interfaceName)
+	}
+
+	output, err := w.run(ctx, "show", interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute wg show %s: %w", interfaceName, err)
+	}
+
+	return parseHumanOutput(w.log(), interfaceName, string(output), time.Now())
+}
+
+// Parse the human-readable format:
+//
+//	interface: wg0
+//	  public key: <key>
+//	  listening port: 51820
+//
+//	peer: <key>
+//	  endpoint: 203.0.113.1:51820
+//	  allowed ips: 10.0.0.2/32
+//	  latest handshake: 1 minute, 5 seconds ago
+//	  transfer: 1.50 KiB received, 3.20 MiB sent
+func parseHumanOutput(logger *slog.Logger, interfaceName, output string, now time.Time) (*Interface, error) {
+	iface := &Interface{
+		Name:  interfaceName,
+		Peers: []Peer{},
+	}
+
+	var peer *Peer
+	foundInterface := false
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if matches := interfacePattern.FindStringSubmatch(line); matches != nil {
+			foundInterface = true
+			continue
+		}
+
+		if matches := peerPattern.FindStringSubmatch(line); matches != nil {
+			if peer != nil {
+				iface.Peers = append(iface.Peers, *peer)
+			}
+			peer = &Peer{
+				PublicKey:  matches[1],
+				AllowedIPs: []string{},
+			}
+			continue
+		}
+
+		matches := fieldPattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		key, value := matches[1], strings.TrimSpace(matches[2])
+
+		// Fields before the first peer belong to the interface
+		if peer == nil {
+			switch key {
+			case "public key":
+				iface.PublicKey = value
+			case "listening port":
+				if port, ok := parseListeningPort(logger, interfaceName, value); ok {
+					iface.ListeningPort = port
+				} else {
+					iface.ListeningPort = unknownListeningPort
+				}
+			case "fwmark":
+				if fwmark, err := strconv.ParseUint(value, 0, 32); err == nil {
+					iface.FwMark = uint32(fwmark)
+				}
+			}
+			continue
+		}
+
+		switch key {
+		case "preshared key":
+			peer.HasPresharedKey = value != "(none)"
+		case "endpoint":
+			if value != "(none)" {
+				setEndpoint(peer, value)
+			}
+		case "allowed ips":
+			if value != "(none)" {
+				for _, ip := range strings.Split(value, ",") {
+					peer.AllowedIPs = append(peer.AllowedIPs, strings.TrimSpace(ip))
+				}
+			}
+		case "latest handshake":
+			if handshake, err := ParseHandshakeTime(value, now); err == nil {
+				peer.LatestHandshake = handshake
+			} else {
+				logger.Debug("Failed to parse latest handshake", "interface", interfaceName, "value", value, "error", err)
+			}
+		case "transfer":
+			if rx, tx, err := ParseTransferStats(value); err == nil {
+				peer.BytesReceived = rx
+				peer.BytesSent = tx
+			} else {
+				logger.Debug("Failed to parse transfer stats", "interface", interfaceName, "value", value, "error", err)
+			}
+		case "persistent keepalive":
+			// e.g. "every 25 seconds", the line is left out when keepalive is off
+			if keepalive, err := parseHumanDuration(strings.TrimPrefix(value, "every ")); err == nil {
+				peer.PersistentKeepalive = int(keepalive.Seconds())
+			} else {
+				logger.Debug("Failed to parse persistent keepalive", "interface", interfaceName, "value", value, "error", err)
+			}
+		}
+	}
+
+	if peer != nil {
+		iface.Peers = append(iface.Peers, *peer)
+	}
+
+	if !foundInterface {
+		return nil, fmt.Errorf("no interface found in wg show output")
+	}
+
+	logger.Debug("Parsed interface data", "interface", interfaceName, "peers", len(iface.Peers), "format", "human")
+	return iface, nil
+}
+
+// ParseHandshakeTime converts a relative handshake like "1 hour, 2 minutes, 3 seconds ago"
+// into an absolute time, relative to now. Resolution is one second
+func ParseHandshakeTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "now") {
+		return now, nil
+	}
+
+	age, err := parseHumanDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid handshake time: %q", value)
+	}
+	return now.Add(-age), nil
+}
+
+// Convert a duration written by wg like "1 minute, 40 seconds"
+func parseHumanDuration(value string) (time.Duration, error) {
+	matches := durationPattern.FindAllStringSubmatch(value, -1)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid duration: %q", value)
+	}
+
+	var d time.Duration
+	for _, m := range matches {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %q", value)
+		}
+		switch m[2] {
+		case "year":
+			d += time.Duration(n) * 365 * 24 * time.Hour
+		case "day":
+			d += time.Duration(n) * 24 * time.Hour
+		case "hour":
+			d += time.Duration(n) * time.Hour
+		case "minute":
+			d += time.Duration(n) * time.Minute
+		case "second":
+			d += time.Duration(n) * time.Second
+		}
+	}
+	return d, nil
+}
+
+// ParseTransferStats parses "1.50 KiB received, 3.20 MiB sent" into received and sent bytes.
+// Values are rounded by wg, so they are approximate
+func ParseTransferStats(value string) (uint64, uint64, error) {
+	matches := transferPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if matches == nil {
+		return 0, 0, fmt.Errorf("invalid transfer stats: %q", value)
+	}
+
+	rx, err := parseByteSize(matches[1], matches[2])
+	if err != nil {
+		return 0, 0, err
+	}
+	tx, err := parseByteSize(matches[3], matches[4])
+	if err != nil {
+		return 0, 0, err
+	}
+	return rx, tx, nil
+}
+
+func parseByteSize(number, unit string) (uint64, error) {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte count %q: %w", number, err)
+	}
+
+	multiplier := map[string]float64{
+		"B":   1,
+		"KiB": 1 << 10,
+		"MiB": 1 << 20,
+		"GiB": 1 << 30,
+		"TiB": 1 << 40,
+		"PiB": 1 << 50,
+		"EiB": 1 << 60,
+	}[unit]
+
+	return uint64(value * multiplier), nil
+}
+
+// ParseShowconf runs "wg showconf <interface>" and returns the [Peer] data it adds to the dump,
+// keyed by public key. The output contains private and preshared keys, it is never logged and
+// only the presence of a preshared key is kept
+func (w *WGClient) ParseShowconf(ctx context.Context, interfaceName string) (map[string]ShowconfPeer, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Validate interface name for security
+	if !isValidInterfaceName(interfaceName) {
+		return nil, fmt.Errorf("invalid interface name: %s", interfaceName)
+	}
+
+	output, err := w.run(ctx, "showconf", interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute wg showconf %s: %w", interfaceName, err)
+	}
+
+	return parseShowconfOutput(w.log(), string(output)), nil
+}
+
+func parseShowconfOutput(logger *slog.Logger, output string) map[string]ShowconfPeer {
+	peers := make(map[string]ShowconfPeer)
+
+	displayNameRegex := regexp.MustCompile(`(?i)^\s*#\s*display[-_]name\s*=\s*(.+)$`)
+	publicKeyRegex := regexp.MustCompile(`(?i)^\s*PublicKey\s*=\s*(.+)$`)
+	presharedKeyRegex := regexp.MustCompile(`(?i)^\s*PresharedKey\s*=`)
+
+	var inPeerSection bool
+	var publicKey string
+	var current ShowconfPeer
+
+	// Store the peer being parsed, if it had a public key
+	flush := func() {
+		if inPeerSection && publicKey != "" {
+			peers[publicKey] = current
+		}
+		publicKey = ""
+		current = ShowconfPeer{}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmedLine := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmedLine, "[") {
+			flush()
+			inPeerSection = trimmedLine == "[Peer]"
+			continue
+		}
+
+		if !inPeerSection {
+			continue
+		}
+
+		if matches := displayNameRegex.FindStringSubmatch(trimmedLine); matches != nil {
+			current.DisplayName = strings.TrimSpace(matches[1])
+		} else if matches := publicKeyRegex.FindStringSubmatch(trimmedLine); matches != nil {
+			publicKey = strings.TrimSpace(matches[1])
+		} else if presharedKeyRegex.MatchString(trimmedLine) {
+			current.HasPresharedKey = true
+		}
+	}
+	flush()
+
+	logger.Debug("Parsed wg showconf output", "peers", len(peers))
+	return peers
+}
@@ -0,0 +1,369 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner answers wg commands with canned output, keyed by the space-joined arguments
+type fakeRunner map[string]string
+
+func (f fakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	output, exists := f[strings.Join(args, " ")]
+	if !exists {
+		return nil, fmt.Errorf("unexpected command %s %s", name, strings.Join(args, " "))
+	}
+	return []byte(output), nil
+}
+
+func newFakeClient(runner fakeRunner) *WGClient {
+	return &WGClient{Command: []string{"wg"}, Runner: runner}
+}
+
+const (
+	testPeerA = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+	testPeerB = "TrMvSoP4jYQlY6RIzBgbssQqY3vxI2Pi+y71lOWWXX0="
+)
+
+func TestParseInterfaceData(t *testing.T) {
+	tests := []struct {
+		name    string
+		dump    string
+		wantErr bool
+		check   func(t *testing.T, iface *Interface)
+	}{
+		{
+			name: "interface with two peers",
+			dump: "PRIV\tPUB0\t51820\toff\n" +
+				testPeerA + "\t(none)\t1.2.3.4:5555\t10.0.0.2/32,10.0.0.0/24\t1700000000\t100\t200\t25\n" +
+				testPeerB + "\tPSK\t[2001:db8::1]:51820\t10.0.0.3/32\t0\t0\t0\toff\n",
+			check: func(t *testing.T, iface *Interface) {
+				if iface.PublicKey != "PUB0" || iface.ListeningPort != 51820 || iface.FwMark != 0 {
+					t.Errorf("interface = %+v", iface)
+				}
+				if len(iface.Peers) != 2 {
+					t.Fatalf("got %d peers, want 2", len(iface.Peers))
+				}
+
+				a := iface.Peers[0]
+				if a.PublicKey != testPeerA || a.HasPresharedKey || a.EndpointIP != "1.2.3.4" || a.EndpointPort != 5555 {
+					t.Errorf("peer A = %+v", a)
+				}
+				if len(a.AllowedIPs) != 2 || a.AllowedIPs[1] != "10.0.0.0/24" {
+					t.Errorf("peer A allowed IPs = %v", a.AllowedIPs)
+				}
+				if !a.LatestHandshake.Equal(time.Unix(1700000000, 0)) {
+					t.Errorf("peer A latest handshake = %v", a.LatestHandshake)
+				}
+				if a.BytesReceived != 100 || a.BytesSent != 200 || a.PersistentKeepalive != 25 {
+					t.Errorf("peer A counters = %d/%d, keepalive %d", a.BytesReceived, a.BytesSent, a.PersistentKeepalive)
+				}
+
+				b := iface.Peers[1]
+				if !b.HasPresharedKey || b.EndpointIP != "2001:db8::1" || b.EndpointFamily != "ipv6" {
+					t.Errorf("peer B = %+v", b)
+				}
+				if !b.LatestHandshake.IsZero() || b.PersistentKeepalive != 0 {
+					t.Errorf("peer B handshake %v, keepalive %d", b.LatestHandshake, b.PersistentKeepalive)
+				}
+			},
+		},
+		{
+			name: "hex fwmark",
+			dump: "PRIV\tPUB0\t51820\t0xca6c\n",
+			check: func(t *testing.T, iface *Interface) {
+				if iface.FwMark != 0xca6c {
+					t.Errorf("fwmark = %#x, want 0xca6c", iface.FwMark)
+				}
+			},
+		},
+		{
+			name: "listening port off",
+			dump: "PRIV\tPUB0\toff\toff\n",
+			check: func(t *testing.T, iface *Interface) {
+				if iface.ListeningPort != 0 {
+					t.Errorf("listening port = %d, want 0", iface.ListeningPort)
+				}
+			},
+		},
+		{
+			name: "out of range listening port",
+			dump: "PRIV\tPUB0\t99999\toff\n",
+			check: func(t *testing.T, iface *Interface) {
+				if iface.ListeningPort != unknownListeningPort {
+					t.Errorf("listening port = %d, want unknown", iface.ListeningPort)
+				}
+			},
+		},
+		{
+			name: "empty dump of an unconfigured interface",
+			dump: "",
+			check: func(t *testing.T, iface *Interface) {
+				if iface.Name != "wg0" || len(iface.Peers) != 0 {
+					t.Errorf("interface = %+v", iface)
+				}
+			},
+		},
+		{
+			name: "truncated peer line is skipped",
+			dump: "PRIV\tPUB0\t51820\toff\n" +
+				testPeerA + "\t(none)\t1.2.3.4:5555\n",
+			check: func(t *testing.T, iface *Interface) {
+				if len(iface.Peers) != 0 {
+					t.Errorf("got %d peers, want 0", len(iface.Peers))
+				}
+			},
+		},
+		{
+			name: "extra columns of newer wg versions are kept aside",
+			dump: "PRIV\tPUB0\t51820\toff\n" +
+				testPeerA + "\t(none)\t(none)\t10.0.0.2/32\t0\t0\t0\toff\tnew\n",
+			check: func(t *testing.T, iface *Interface) {
+				if len(iface.Peers) != 1 || len(iface.Peers[0].ExtraFields) != 1 || iface.Peers[0].ExtraFields[0] != "new" {
+					t.Errorf("peers = %+v", iface.Peers)
+				}
+			},
+		},
+		{
+			name:    "invalid interface line",
+			dump:    "PRIV\tPUB0\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(fakeRunner{"show wg0 dump": tt.dump})
+			iface, err := client.ParseInterfaceData(context.Background(), "wg0")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", iface)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, iface)
+		})
+	}
+}
+
+func TestParseInterfaceDataInvalidName(t *testing.T) {
+	client := newFakeClient(fakeRunner{})
+	if _, err := client.ParseInterfaceData(context.Background(), "wg0; rm -rf /"); err == nil {
+		t.Fatal("expected an error for an invalid interface name")
+	}
+}
+
+func TestSplitEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint       string
+		ip, zone, port string
+	}{
+		{endpoint: "1.2.3.4:51820", ip: "1.2.3.4", port: "51820"},
+		{endpoint: "[2001:db8::1]:51820", ip: "2001:db8::1", port: "51820"},
+		{endpoint: "[fe80::1%eth0]:51820", ip: "fe80::1", zone: "eth0", port: "51820"},
+		{endpoint: "[2001:db8::1]", ip: "2001:db8::1"},
+		{endpoint: "1.2.3.4", ip: "1.2.3.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			ip, zone, port := splitEndpoint(tt.endpoint)
+			if ip != tt.ip || zone != tt.zone || port != tt.port {
+				t.Errorf("splitEndpoint(%q) = %q, %q, %q, want %q, %q, %q", tt.endpoint, ip, zone, port, tt.ip, tt.zone, tt.port)
+			}
+		})
+	}
+}
+
+func TestDumpFields(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{name: "interface line", line: "PRIV\tPUB0\t51820\toff", want: []string{"PRIV", "PUB0", "51820", "off"}},
+		{name: "value with a space", line: "KEY\t(none)\tsome host:51820\t10.0.0.2/32", want: []string{"KEY", "(none)", "some host:51820", "10.0.0.2/32"}},
+		{name: "empty value", line: "KEY\t\t(none)", want: []string{"KEY", "", "(none)"}},
+		{name: "carriage return", line: "PRIV\tPUB0\t51820\toff\r", want: []string{"PRIV", "PUB0", "51820", "off"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dumpFields(tt.line); !slices.Equal(got, tt.want) {
+				t.Errorf("dumpFields(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInterfaceDataHuman(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr bool
+		check   func(t *testing.T, iface *Interface)
+	}{
+		{
+			name: "interface with two peers",
+			output: "interface: wg0\n" +
+				"  public key: PUB0\n" +
+				"  private key: (hidden)\n" +
+				"  listening port: 51820\n" +
+				"  fwmark: 0xca6c\n" +
+				"\n" +
+				"peer: " + testPeerA + "\n" +
+				"  preshared key: (hidden)\n" +
+				"  endpoint: 1.2.3.4:5555\n" +
+				"  allowed ips: 10.0.0.2/32, 10.0.0.0/24\n" +
+				"  latest handshake: 1 minute, 5 seconds ago\n" +
+				"  transfer: 1.50 KiB received, 3.20 MiB sent\n" +
+				"  persistent keepalive: every 25 seconds\n" +
+				"\n" +
+				"peer: " + testPeerB + "\n" +
+				"  allowed ips: (none)\n",
+			check: func(t *testing.T, iface *Interface) {
+				if iface.PublicKey != "PUB0" || iface.ListeningPort != 51820 || iface.FwMark != 0xca6c {
+					t.Errorf("interface = %+v", iface)
+				}
+				if len(iface.Peers) != 2 {
+					t.Fatalf("got %d peers, want 2", len(iface.Peers))
+				}
+
+				a := iface.Peers[0]
+				if a.PublicKey != testPeerA || !a.HasPresharedKey || a.EndpointIP != "1.2.3.4" || a.EndpointPort != 5555 {
+					t.Errorf("peer A = %+v", a)
+				}
+				if !slices.Equal(a.AllowedIPs, []string{"10.0.0.2/32", "10.0.0.0/24"}) {
+					t.Errorf("peer A allowed IPs = %v", a.AllowedIPs)
+				}
+				if age := time.Since(a.LatestHandshake); age < 65*time.Second || age > 70*time.Second {
+					t.Errorf("peer A handshake age = %s, want about 65s", age)
+				}
+				if a.BytesReceived != 1536 || a.BytesSent != 3355443 || a.PersistentKeepalive != 25 {
+					t.Errorf("peer A counters = %d/%d, keepalive %d", a.BytesReceived, a.BytesSent, a.PersistentKeepalive)
+				}
+
+				b := iface.Peers[1]
+				if b.HasPresharedKey || b.Endpoint != "" || len(b.AllowedIPs) != 0 || !b.LatestHandshake.IsZero() || b.PersistentKeepalive != 0 {
+					t.Errorf("peer B = %+v", b)
+				}
+			},
+		},
+		{
+			name:   "interface without peers",
+			output: "interface: wg0\n  listening port: 51820\n",
+			check: func(t *testing.T, iface *Interface) {
+				if iface.ListeningPort != 51820 || len(iface.Peers) != 0 {
+					t.Errorf("interface = %+v", iface)
+				}
+			},
+		},
+		{
+			name:   "unparsable values are skipped",
+			output: "interface: wg0\n\npeer: " + testPeerA + "\n  latest handshake: sometime\n  transfer: lots received, lots sent\n",
+			check: func(t *testing.T, iface *Interface) {
+				if len(iface.Peers) != 1 || !iface.Peers[0].LatestHandshake.IsZero() || iface.Peers[0].BytesReceived != 0 {
+					t.Errorf("peers = %+v", iface.Peers)
+				}
+			},
+		},
+		{
+			name:    "no interface line",
+			output:  "peer: " + testPeerA + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeClient(fakeRunner{"show wg0": tt.output})
+			iface, err := client.ParseInterfaceDataHuman(context.Background(), "wg0")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", iface)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, iface)
+		})
+	}
+}
+
+func TestParseHandshakeTime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "Now", want: 0},
+		{value: "now", want: 0},
+		{value: "1 second ago", want: time.Second},
+		{value: "1 minute, 5 seconds ago", want: 65 * time.Second},
+		{value: "2 hours, 1 minute, 40 seconds ago", want: 2*time.Hour + 100*time.Second},
+		{value: "1 year, 3 days ago", want: 368 * 24 * time.Hour},
+		{value: "(none)", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseHandshakeTime(tt.value, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if age := now.Sub(got); age != tt.want {
+				t.Errorf("age = %s, want %s", age, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTransferStats(t *testing.T) {
+	tests := []struct {
+		value   string
+		rx, tx  uint64
+		wantErr bool
+	}{
+		{value: "0 B received, 92 B sent", rx: 0, tx: 92},
+		{value: "1.50 KiB received, 3.20 MiB sent", rx: 1536, tx: 3355443},
+		{value: "2 GiB received, 1.25 TiB sent", rx: 2 << 30, tx: 5 << 38},
+		{value: "10 KB received, 1 B sent", wantErr: true},
+		{value: "1 B received", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			rx, tx, err := ParseTransferStats(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d/%d", rx, tx)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rx != tt.rx || tx != tt.tx {
+				t.Errorf("got %d received, %d sent, want %d, %d", rx, tx, tt.rx, tt.tx)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package wireguard
+
+import (
+	"container/list"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hostnameCacheSize = 4096      // Endpoint IPs kept, the least recently used are dropped first
+	hostnameCacheTTL  = time.Hour // Age after which a hostname is looked up again
+	hostnameTimeout   = 2 * time.Second
+)
+
+// Reverse DNS names of endpoint IPs in a bounded LRU cache. Lookups run in the background so
+// scrapes never wait for DNS: a new IP has no hostname until its lookup finished, and an
+// expired one keeps its old hostname while it is looked up again
+type hostnameResolver struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // Keyed by IP, values are *hostnameEntry
+	order   *list.List               // Most recently used first
+
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+}
+
+type hostnameEntry struct {
+	ip       string
+	hostname string // Empty if the lookup failed or found no name
+	resolved time.Time
+	pending  bool // A lookup is running
+}
+
+func newHostnameResolver() *hostnameResolver {
+	return &hostnameResolver{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		lookupAddr: net.DefaultResolver.LookupAddr,
+	}
+}
+
+// Cached hostname of ip, starting a lookup if it is unknown or expired
+func (r *hostnameResolver) hostname(ip string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if elem, ok := r.entries[ip]; ok {
+		r.order.MoveToFront(elem)
+		entry := elem.Value.(*hostnameEntry)
+		if !entry.pending && time.Since(entry.resolved) > hostnameCacheTTL {
+			entry.pending = true
+			go r.resolve(entry)
+		}
+		return entry.hostname
+	}
+
+	entry := &hostnameEntry{ip: ip, pending: true}
+	r.entries[ip] = r.order.PushFront(entry)
+	if r.order.Len() > hostnameCacheSize {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*hostnameEntry).ip)
+	}
+	go r.resolve(entry)
+	return ""
+}
+
+// Look up the hostname of the entry. Failures are cached too, so an IP without a PTR
+// record is not looked up on every scrape
+func (r *hostnameResolver) resolve(entry *hostnameEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), hostnameTimeout)
+	defer cancel()
+
+	hostname := ""
+	if names, err := r.lookupAddr(ctx, entry.ip); err == nil && len(names) > 0 {
+		hostname = strings.TrimSuffix(names[0], ".")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.hostname = hostname
+	entry.resolved = time.Now()
+	entry.pending = false
+}
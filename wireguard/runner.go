@@ -0,0 +1,188 @@
+package wireguard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandRunner runs a command and returns its standard output. It is the only place
+// commands are executed, so wg can be replaced by canned output
+type CommandRunner interface {
+	Run(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// ExecRunner runs commands with os/exec. They inherit the exporter's environment,
+// with Env set on top of it
+type ExecRunner struct {
+	Env map[string]string
+}
+
+func (r ExecRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if len(r.Env) > 0 {
+		// For duplicate names the last value wins, so these override the inherited ones
+		keys := make([]string, 0, len(r.Env))
+		for key := range r.Env {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		cmd.Env = os.Environ()
+		for _, key := range keys {
+			cmd.Env = append(cmd.Env, key+"="+r.Env[key])
+		}
+	}
+	return cmd.Output()
+}
+
+// WGClient runs wg commands through a CommandRunner
+type WGClient struct {
+	Command []string // wg itself, or a wrapper and its leading arguments
+	Retries int      // Retries when a command fails to execute, 0 disables
+	Runner  CommandRunner
+	Logger  *slog.Logger // Logger for parse and retry messages, nil uses the default
+}
+
+// Create a client running wgCommand with os/exec
+func NewWGClient(wgCommand []string, retries int) *WGClient {
+	return &WGClient{
+		Command: wgCommand,
+		Retries: retries,
+		Runner:  ExecRunner{},
+	}
+}
+
+// Logger for the client's messages, e.g. carrying the scrape ID of the current collection
+func (w *WGClient) log() *slog.Logger {
+	if w.Logger == nil {
+		return slog.Default()
+	}
+	return w.Logger
+}
+
+// Run wg with args once, after the wrapper and leading arguments of the command
+func (w *WGClient) runOnce(ctx context.Context, args ...string) ([]byte, error) {
+	argv := append(slices.Clone(w.Command[1:]), args...)
+	return w.Runner.Run(ctx, w.Command[0], argv...)
+}
+
+// Run a wg command, retrying up to Retries times with a short exponential backoff when it
+// fails to execute. All attempts share the deadline of ctx
+func (w *WGClient) run(ctx context.Context, args ...string) ([]byte, error) {
+	backoff := 100 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		output, err := w.runOnce(ctx, args...)
+		if err == nil || attempt >= w.Retries {
+			return output, err
+		}
+
+		w.log().Debug("wg command failed, retrying", "args", args, "attempt", attempt+1, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// DumpFileRunner answers wg commands from a captured "wg show <interface> dump" file instead
+// of running wg, for offline analysis and demos. The interface is named after the file.
+// The path can also be a named pipe, then every scrape waits for a test harness to write
+// the next dump, so resets, roaming and deltas can be replayed over time
+type DumpFileRunner struct {
+	path  string
+	iface string
+	stdin []byte // Dump read from stdin at creation, it cannot be read again
+	fifo  bool
+
+	mu      sync.Mutex
+	pending *fifoRead // Read of the named pipe still waiting for a writer, nil if none
+}
+
+// One read of the named pipe, done is closed once data and err are set
+type fifoRead struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Create a runner for the dump in path, "-" reads the dump from stdin once
+func NewDumpFileRunner(path string) (*DumpFileRunner, error) {
+	if path == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dump from stdin: %w", err)
+		}
+		return &DumpFileRunner{path: path, iface: "stdin", stdin: data}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump file: %w", err)
+	}
+
+	// The name has to pass the interface name validation, e.g. "wg0" for "wg0.dump"
+	iface := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if !isValidInterfaceName(iface) {
+		iface = "dump"
+	}
+	return &DumpFileRunner{path: path, iface: iface, fifo: info.Mode()&os.ModeNamedPipe != 0}, nil
+}
+
+func (r *DumpFileRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	switch {
+	case slices.Equal(args, []string{"show", "interfaces"}):
+		return []byte(r.iface + "\n"), nil
+	case slices.Equal(args, []string{"show", r.iface, "dump"}):
+		if r.path == "-" {
+			return r.stdin, nil
+		}
+		if r.fifo {
+			return r.readFIFO(ctx)
+		}
+		// Read again on every scrape so an updated capture is picked up
+		return os.ReadFile(r.path)
+	default:
+		return nil, fmt.Errorf("wg %s is not available when reading from a dump file", strings.Join(args, " "))
+	}
+}
+
+// Read the next dump written to the named pipe. Opening blocks until a writer shows up and
+// cannot be interrupted, so a read abandoned by a timed out scrape is picked up by the next
+// one instead of starting another
+func (r *DumpFileRunner) readFIFO(ctx context.Context) ([]byte, error) {
+	r.mu.Lock()
+	read := r.pending
+	if read == nil {
+		read = &fifoRead{done: make(chan struct{})}
+		r.pending = read
+		go func() {
+			read.data, read.err = os.ReadFile(r.path)
+			close(read.done)
+		}()
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-read.done:
+		r.mu.Lock()
+		if r.pending == read {
+			r.pending = nil
+		}
+		r.mu.Unlock()
+		return read.data, read.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no dump written to %s: %w", r.path, ctx.Err())
+	}
+}
@@ -0,0 +1,105 @@
+package wireguard
+
+import (
+	"slices"
+	"sync"
+
+	"wireguard-exporter-go/config"
+)
+
+// TargetCollectors holds one collector per remote target of the multi-target pattern, created
+// on the first probe of the target. Each keeps its own counters, so interfaces with the same
+// name on different hosts don't mix
+type TargetCollectors struct {
+	mu         sync.Mutex
+	cfg        *config.Config
+	collectors map[string]*Collector
+
+	runner CommandRunner // Runs the wg commands of all targets, nil for os/exec with CommandEnv
+}
+
+func NewTargetCollectors(cfg *config.Config) *TargetCollectors {
+	return NewTargetCollectorsWithRunner(cfg, nil)
+}
+
+// NewTargetCollectorsWithRunner creates the target collectors running the wg commands through
+// runner, like NewCollectorWithRunner
+func NewTargetCollectorsWithRunner(cfg *config.Config, runner CommandRunner) *TargetCollectors {
+	return &TargetCollectors{
+		cfg:        cfg,
+		collectors: make(map[string]*Collector),
+		runner:     runner,
+	}
+}
+
+// Collector of the target, false if the target is not in RemoteTargets. A new collector runs
+// "wg --version" on the target, which can take a while over SSH, so it is created without
+// holding mu and other targets are not held up
+func (t *TargetCollectors) Get(target string) (*Collector, bool) {
+	t.mu.Lock()
+	cfg := t.cfg
+	command, exists := cfg.RemoteTargets[target]
+	collector, created := t.collectors[target]
+	t.mu.Unlock()
+
+	if !exists {
+		return nil, false
+	}
+	if created {
+		return collector, true
+	}
+
+	runner := t.runner
+	if runner == nil {
+		runner = ExecRunner{Env: cfg.CommandEnv}
+	}
+	collector = NewCollectorWithRunner(targetConfig(cfg, command), runner)
+	collector.remote = true
+
+	t.mu.Lock()
+	if existing, created := t.collectors[target]; created {
+		// Another probe of the target was faster
+		t.mu.Unlock()
+		return existing, true
+	}
+	if t.cfg != cfg {
+		// Reloaded in the meantime, the command may have changed or the target may be gone
+		t.mu.Unlock()
+		return t.Get(target)
+	}
+	t.collectors[target] = collector
+	t.mu.Unlock()
+	return collector, true
+}
+
+// Replace the configuration of all targets, collectors of removed targets are dropped.
+// Like in Get, a changed command is run outside of mu
+func (t *TargetCollectors) SetConfig(cfg *config.Config) {
+	t.mu.Lock()
+	t.cfg = cfg
+	updates := make(map[*Collector]*config.Config)
+	for target, collector := range t.collectors {
+		command, exists := cfg.RemoteTargets[target]
+		if !exists {
+			delete(t.collectors, target)
+			continue
+		}
+		updates[collector] = targetConfig(cfg, command)
+	}
+	t.mu.Unlock()
+
+	for collector, targetCfg := range updates {
+		collector.SetConfig(targetCfg)
+	}
+}
+
+// Configuration of a target: its own wg command and nothing read from local files, which
+// describe this host rather than the target
+func targetConfig(cfg *config.Config, command []string) *config.Config {
+	targetCfg := *cfg
+	targetCfg.WGCommand = slices.Clone(command)
+	targetCfg.DumpFile = ""
+	targetCfg.ReadConfigFiles = false
+	targetCfg.StateFile = ""
+	return &targetCfg
+}
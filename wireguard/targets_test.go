@@ -0,0 +1,73 @@
+package wireguard
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// targetRunner blocks the commands of the slow executable until release, the others answer at once
+type targetRunner struct {
+	slow    string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *targetRunner) Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if name == r.slow {
+		r.started <- struct{}{}
+		select {
+		case <-r.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return []byte("wireguard-tools v1.0.20210914\n"), nil
+}
+
+// Creating the collector of a slow target runs wg --version there, other targets must not wait for it
+func TestTargetCollectorsGetDoesNotBlock(t *testing.T) {
+	cfg := testConfig()
+	cfg.RemoteTargets = map[string][]string{
+		"slow": {"wg-slow"},
+		"fast": {"wg-fast"},
+	}
+	runner := &targetRunner{slow: "wg-slow", started: make(chan struct{}, 1), release: make(chan struct{})}
+	targets := NewTargetCollectorsWithRunner(cfg, runner)
+
+	slowDone := make(chan *Collector)
+	go func() {
+		collector, _ := targets.Get("slow")
+		slowDone <- collector
+	}()
+	select {
+	case <-runner.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow target never ran wg")
+	}
+
+	// Answered while the slow target is still being created, this would block forever otherwise
+	fastDone := make(chan *Collector)
+	go func() {
+		collector, _ := targets.Get("fast")
+		fastDone <- collector
+	}()
+	var fast *Collector
+	select {
+	case fast = <-fastDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("getting the fast target waited for the slow one")
+	}
+
+	close(runner.release)
+	if slow := <-slowDone; slow == nil || slow == fast {
+		t.Errorf("slow target collector = %p, fast = %p", slow, fast)
+	}
+
+	if _, exists := targets.Get("unknown"); exists {
+		t.Error("unconfigured target found")
+	}
+	if again, _ := targets.Get("fast"); again != fast {
+		t.Error("a target got a new collector on every probe")
+	}
+}
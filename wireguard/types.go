@@ -1,23 +1,53 @@
-package wireguard
-
-import "time"
-
-// Interface represents a WireGuard interface with its configuration and peers
-type Interface struct {
-	Name         string
-	PublicKey    string
-	ListeningPort int
-	Peers        []Peer
-}
-
-// Peer represents a WireGuard peer connection
-type Peer struct {
-	PublicKey      string
-	DisplayName    string // Human-friendly name from config file, empty if not available
-	Endpoint       string // IP:port or empty if not connected
-	AllowedIPs     []string
-	LatestHandshake time.Time // Zero value if never connected
-	BytesSent      uint64
-	BytesReceived  uint64
-}
-
+package wireguard
+
+import "time"
+
+// Interface represents a WireGuard interface with its configuration and peers
+type Interface struct {
+	Name          string `json:"name"`
+	PublicKey     string `json:"public_key"`
+	ListeningPort int    `json:"listening_port"` // 0 if off, -1 if wg printed an invalid port
+	FwMark        uint32 `json:"fwmark"` // 0 if off
+	MTU           int    `json:"mtu,omitempty"` // Read from sysfs, 0 if unknown
+	Addresses     []string `json:"addresses,omitempty"` // Address lines of the [Interface] section of the config file, empty if it was not read
+	Peers         []Peer `json:"peers"`
+
+	ConfigModTime time.Time `json:"config_mod_time"` // Modification time of the config file, zero if it was not read
+}
+
+// Peer represents a WireGuard peer connection
+type Peer struct {
+	PublicKey       string    `json:"public_key"`
+	DisplayName     string    `json:"display_name"`    // Human-friendly name from config file, empty if not available
+	Endpoint        string    `json:"endpoint"`        // IP:port or empty if not connected
+	EndpointIP      string    `json:"endpoint_ip"`     // IP part of Endpoint, without IPv6 brackets and zone
+	EndpointZone    string    `json:"endpoint_zone"`   // IPv6 zone of Endpoint, e.g. "eth0" for "[fe80::1%eth0]:51820"
+	EndpointPort    int       `json:"endpoint_port"`   // Port part of Endpoint, 0 if unknown
+	EndpointFamily  string    `json:"endpoint_family"` // "ipv4", "ipv6" or "unknown" (e.g. hostnames), empty without endpoint
+	EndpointType    string    `json:"endpoint_type"`   // "static" with an Endpoint in the config file, "roaming" without, empty if the file was not read
+	AllowedIPs      []string  `json:"allowed_ips"`
+	LatestHandshake time.Time `json:"latest_handshake"` // Zero value if never connected
+	BytesSent       uint64    `json:"bytes_sent"`
+	BytesReceived   uint64    `json:"bytes_received"`
+	HasPresharedKey bool      `json:"has_preshared_key"` // Only presence, the key itself is never read
+	PersistentKeepalive int   `json:"persistent_keepalive"` // Keepalive interval in seconds, 0 if off
+	ExtraFields     []string  `json:"extra_fields,omitempty"` // Dump columns after the known ones, added by newer wg versions and not interpreted yet
+}
+
+// ShowconfPeer holds what "wg showconf" adds for a peer, keyed by public key
+type ShowconfPeer struct {
+	DisplayName     string // From a display-name comment, usually empty since wg does not keep comments
+	HasPresharedKey bool
+}
+
+// ConfigFile holds what the exporter reads from a WireGuard config file
+type ConfigFile struct {
+	Addresses []string              // Addresses of the interface itself, IPv4 and IPv6, as written
+	Peers     map[string]ConfigPeer // Keyed by public key
+}
+
+// ConfigPeer holds what a WireGuard config file says about a peer, keyed by public key
+type ConfigPeer struct {
+	DisplayName string // From a display-name comment, empty if there is none
+	HasEndpoint bool   // Whether the section sets Endpoint, i.e. this side initiates the connection
+}